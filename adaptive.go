@@ -0,0 +1,55 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// adaptiveMinAttempts is the number of attempts a host needs in its window before its budget is scaled,
+// so a few early failures don't cut it down.
+const adaptiveMinAttempts = 10
+
+// adaptiveRetry scales the number of attempts to a host down while its failure rate over the window of
+// its HostStats stays above the threshold. The rate drops as failed attempts leave the window, so the
+// attempt budget recovers gradually rather than snapping back.
+type adaptiveRetry struct {
+	threshold float64
+	stats     *hostStatsTracker
+}
+
+func newAdaptiveRetry(threshold float64) *adaptiveRetry {
+	return &adaptiveRetry{threshold: threshold}
+}
+
+// attempts returns the attempt budget for host, never less than one.
+func (a *adaptiveRetry) attempts(host string, max uint32) uint32 {
+	stats := a.stats.snapshot(host, time.Time{}, time.Now())
+	rate := stats.ErrorRate
+	if stats.Attempts < adaptiveMinAttempts || rate <= a.threshold {
+		return max
+	}
+
+	// Interpolate linearly from the full budget at the threshold down to a single attempt at a 100% failure rate.
+	scaled := uint32(math.Ceil(float64(max) * (1 - rate) / (1 - a.threshold)))
+	if scaled < 1 {
+		return 1
+	}
+
+	return scaled
+}
+
+// WithAdaptiveAttempts enables adaptive retries: while the failure rate of the attempts to a host over
+// the last minute is above threshold (0 to 1, exclusive), see HostStats, the number of attempts is
+// reduced down to a single attempt, and it recovers gradually as requests to the host start succeeding
+// again.
+func WithAdaptiveAttempts(threshold float64) ClientOption {
+	return func(c *Client) error {
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("invalid adaptive attempts threshold '%g'", threshold)
+		}
+		c.adaptive = newAdaptiveRetry(threshold)
+
+		return nil
+	}
+}
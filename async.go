@@ -0,0 +1,140 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Future is the pending result of a request started with DoAsync.
+type Future struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	resp   *http.Response
+	err    error
+
+	// mu decides between completion and cancellation, whichever comes first.
+	mu        sync.Mutex
+	completed bool
+	cancelled bool
+}
+
+// DoAsync starts performing req like DoRequest in a new goroutine, and returns a Future to wait for
+// its result or cancel it.
+func (c *Client) DoAsync(req *http.Request, opts ...RequestOption) *Future {
+	f := &Future{done: make(chan struct{}), cancel: func() {}}
+
+	if req == nil || req.URL == nil {
+		f.err = &ConfigError{Err: fmt.Errorf("invalid http request: missing url")}
+		close(f.done)
+		return f
+	}
+
+	ro, err := newRequestOptions(opts)
+	if err != nil {
+		f.err = err
+		close(f.done)
+		return f
+	}
+	if ro.ctx == nil {
+		ro.ctx = req.Context()
+	}
+	ro.ctx, f.cancel = context.WithCancel(ro.ctx)
+
+	go func() {
+		defer close(f.done)
+
+		resp, err := c.send(req, ro)
+
+		f.mu.Lock()
+		if f.cancelled && err == nil {
+			// The response arrived as the request was cancelled, whose context no longer lets its body be read.
+			if resp != nil {
+				resp.Body.Close()
+			}
+			resp, err = nil, fmt.Errorf("request cancelled: %w", context.Canceled)
+		}
+		f.completed = true
+		f.mu.Unlock()
+		f.resp, f.err = resp, err
+
+		// Keep the future context alive until the caller is done with the response body.
+		if f.resp != nil && f.err == nil {
+			f.resp.Body = &releaseOnClose{ReadCloser: f.resp.Body, release: f.cancel}
+		} else {
+			f.cancel()
+		}
+	}()
+
+	return f
+}
+
+// Done returns a channel closed once the request is complete.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the request to complete and returns its result as DoRequest would. If ctx is done
+// first, Wait returns its error without cancelling the request.
+func (f *Future) Wait(ctx context.Context) (*http.Response, error) {
+	select {
+	case <-f.done:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel cancels the request, interrupting the attempt or backoff in progress. Cancelling a completed
+// request is a no-op, so the body of its response can still be read.
+func (f *Future) Cancel() {
+	f.mu.Lock()
+	if f.completed {
+		f.mu.Unlock()
+		return
+	}
+	f.cancelled = true
+	f.mu.Unlock()
+
+	f.cancel()
+}
+
+// defaultCallbackWorkers is the default number of DoCallback attempts in progress at once.
+const defaultCallbackWorkers = 8
+
+// WithCallbackWorkers sets the number of workers sending the attempts of DoCallback requests, i.e. the
+// number of callback attempts in progress at once. It defaults to 8.
+func WithCallbackWorkers(n uint32) ClientOption {
+	return func(c *Client) error {
+		if n == 0 {
+			return fmt.Errorf("invalid callback workers value '%d'", n)
+		}
+		c.callbacks = make(semaphore, n)
+
+		return nil
+	}
+}
+
+// DoCallback performs req like DoRequest in the background, and calls callback with the result.
+// DoCallback never blocks. Attempts wait for one of the callback workers of the client, see
+// WithCallbackWorkers, which is released between attempts: a request waiting out a long backoff
+// doesn't hold up the others. A worker is held until the response body is closed, which the callback
+// must do.
+func (c *Client) DoCallback(req *http.Request, callback func(*http.Response, error), opts ...RequestOption) {
+	opts = append(opts[:len(opts):len(opts)], withAttemptSlots(c.callbacks))
+
+	go func() {
+		callback(c.DoRequest(req, opts...))
+	}()
+}
+
+// withAttemptSlots makes every attempt of a request hold a slot of slots, on top of the concurrency
+// limits of the client.
+func withAttemptSlots(slots semaphore) RequestOption {
+	return func(ro *requestOptions) error {
+		ro.slots = slots
+
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AuditRecord is the redacted record of an attempt, delivered to an AuditSink. Credentials and cookies
+// are masked in the headers, and the password and query values in the URL, as in the logs.
+type AuditRecord struct {
+	// Time is when the attempt was sent, and Duration how long it took to complete.
+	Time     time.Time
+	Duration time.Duration
+	// Principal identifies on whose behalf the request was made, see AuditConfig.Principal.
+	Principal string
+	// RequestID is the ID assigned to the request, if request IDs are enabled.
+	RequestID     string
+	Method        string
+	URL           string
+	RequestHeader http.Header
+	// Attempt is the zero-based index of the attempt.
+	Attempt uint32
+	// StatusCode and ResponseHeader describe the response of the attempt, if it got one.
+	StatusCode     int
+	ResponseHeader http.Header
+	// Err is the error that failed the attempt, or empty if its response was accepted.
+	Err string
+}
+
+// AuditSink receives the audit records of the client in batches, e.g. to write them to an append-only
+// store. WriteAudit is called from a single goroutine, so it never runs concurrently.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, records []AuditRecord) error
+}
+
+// AuditConfig configures the delivery of audit records, see WithAuditSink. Zero-valued fields select
+// their default.
+type AuditConfig struct {
+	// BatchSize is the number of records delivered at most per call to the sink. It defaults to 100.
+	BatchSize uint32
+	// FlushInterval is the longest a record waits for its batch to fill up. It defaults to 1 second.
+	FlushInterval time.Duration
+	// QueueSize is the number of records waiting for delivery beyond which new ones are dropped rather
+	// than delaying requests, see ClientStats.AuditDropped. It defaults to 10000.
+	QueueSize uint32
+	// Principal returns the identity on whose behalf a request is made, from the request context.
+	Principal func(ctx context.Context) string
+	// OnError is called with the records the sink failed to write, which are otherwise lost.
+	OnError func(err error, records []AuditRecord)
+}
+
+// WithAuditSink delivers an AuditRecord for every attempt of the client to sink, asynchronously and in
+// batches, so compliance records are kept of every outbound request without slowing requests down.
+// Records still queued when the client is closed are delivered before Close returns.
+func WithAuditSink(sink AuditSink, cfg AuditConfig) ClientOption {
+	return func(c *Client) error {
+		if sink == nil {
+			return fmt.Errorf("nil audit sink")
+		}
+		if cfg.FlushInterval < 0 {
+			return fmt.Errorf("invalid audit flush interval value '%s'", cfg.FlushInterval)
+		}
+		if cfg.BatchSize == 0 {
+			cfg.BatchSize = 100
+		}
+		if cfg.FlushInterval == 0 {
+			cfg.FlushInterval = time.Second
+		}
+		if cfg.QueueSize == 0 {
+			cfg.QueueSize = 10000
+		}
+		c.audit = &auditor{sink: sink, cfg: cfg, queue: make(chan AuditRecord, cfg.QueueSize), done: make(chan struct{})}
+
+		return nil
+	}
+}
+
+// auditor queues the audit records of the client and delivers them to the sink in batches.
+type auditor struct {
+	sink  AuditSink
+	cfg   AuditConfig
+	queue chan AuditRecord
+	// done is closed once the records queued before the client was closed are delivered.
+	done chan struct{}
+}
+
+// run delivers the queued records until the client is closed or ctx is done, then delivers those left
+// and returns. Deliveries aren't cancelled along with ctx, so no record is lost to its cancellation.
+func (a *auditor) run(ctx context.Context, closed <-chan struct{}) {
+	defer close(a.done)
+
+	stop := ctx.Done()
+	ctx = context.WithoutCancel(ctx)
+
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditRecord, 0, a.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.sink.WriteAudit(ctx, batch); err != nil && a.cfg.OnError != nil {
+			a.cfg.OnError(err, batch)
+		}
+		batch = make([]AuditRecord, 0, a.cfg.BatchSize)
+	}
+	add := func(record AuditRecord) {
+		if batch = append(batch, record); uint32(len(batch)) >= a.cfg.BatchSize {
+			flush()
+		}
+	}
+
+	// Deliver what's left in the queue once the client is closed or its context done.
+	drain := func() {
+		for {
+			select {
+			case record := <-a.queue:
+				add(record)
+			default:
+				flush()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-a.queue:
+			add(record)
+		case <-ticker.C:
+			flush()
+		case <-closed:
+			drain()
+			return
+		case <-stop:
+			drain()
+			return
+		}
+	}
+}
+
+// auditAttempt queues the audit record of an attempt, dropping it if the queue is full.
+func (c *Client) auditAttempt(ctx context.Context, req *http.Request, requestID string, attempt uint32, resp *http.Response, timing AttemptTiming) {
+	if c.audit == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:          timing.Start,
+		Duration:      timing.End.Sub(timing.Start),
+		RequestID:     requestID,
+		Method:        req.Method,
+		URL:           loggedURL(req.URL),
+		RequestHeader: loggedHeader(req.Header),
+		Attempt:       attempt,
+	}
+	if c.audit.cfg.Principal != nil {
+		record.Principal = c.audit.cfg.Principal(ctx)
+	}
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+		record.ResponseHeader = loggedHeader(resp.Header)
+	}
+	if timing.Err != nil {
+		record.Err = timing.Err.Error()
+	}
+
+	select {
+	case c.audit.queue <- record:
+	default:
+		c.stats.auditDropped.Add(1)
+	}
+}
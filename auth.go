@@ -0,0 +1,245 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Authenticator adds credentials to each attempt of a request.
+// It's called again for every retry, so credentials may change between attempts.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// Reauthenticator is implemented by authenticators that can renew their credentials, e.g. by logging in
+// again for a session cookie or picking up a rotated API key. When an attempt is rejected with 401
+// Unauthorized, OnUnauthorized is called with the response so the authenticator can drop the refused
+// credentials and obtain new ones, and the attempt is authenticated and sent again right away. This
+// happens once per request: a request rejected again is handled like any other failed attempt. An
+// error from OnUnauthorized fails the attempt.
+type Reauthenticator interface {
+	Authenticator
+	OnUnauthorized(resp *http.Response) error
+}
+
+// challengeResponder is implemented by authenticators answering authentication challenges. When an
+// attempt is rejected with 401 Unauthorized, respondToChallenge sets the credentials answering the
+// challenge of the response on the request, reporting false if there's none to answer, and the attempt
+// is sent again right away. Handshakes spanning several round trips repeat this up to
+// maxChallengeRounds times.
+type challengeResponder interface {
+	respondToChallenge(req *http.Request, resp *http.Response) (bool, error)
+}
+
+// maxChallengeRounds bounds the round trips answering authentication challenges within an attempt.
+const maxChallengeRounds = 3
+
+// WithAuthenticator authenticates every attempt with a, unless the request carries its own credentials.
+// It replaces any other client-level authentication option.
+func WithAuthenticator(a Authenticator) ClientOption {
+	return func(c *Client) error {
+		if a == nil {
+			return fmt.Errorf("nil authenticator")
+		}
+		c.auth = a
+
+		return nil
+	}
+}
+
+// credentials holds a username and password pair for basic authentication.
+type credentials struct {
+	username string
+	password string
+}
+
+func (cr *credentials) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(cr.username, cr.password)
+
+	return nil
+}
+
+// WithBasicAuth authenticates every request with HTTP basic authentication, unless the request
+// sets its own Authorization header or uses WithRequestBasicAuth.
+func WithBasicAuth(username, password string) ClientOption {
+	return WithAuthenticator(&credentials{username: username, password: password})
+}
+
+// WithBearerToken authenticates every request with a static Bearer token, unless the request
+// carries its own credentials.
+func WithBearerToken(token string) ClientOption {
+	return WithBearerTokenFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithBearerTokenFunc authenticates every attempt with a Bearer token returned by fn, unless the
+// request carries its own credentials. fn is called again for each attempt, so tokens rotated
+// in a secrets manager are picked up between retries.
+func WithBearerTokenFunc(fn func(ctx context.Context) (string, error)) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil bearer token function")
+		}
+		c.auth = AuthenticatorFunc(func(req *http.Request) error {
+			token, err := fn(req.Context())
+			if err != nil {
+				return fmt.Errorf("failed to retrieve bearer token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			return nil
+		})
+
+		return nil
+	}
+}
+
+// WithRequestBasicAuth authenticates a single request with HTTP basic authentication,
+// overriding any client-level authentication.
+func WithRequestBasicAuth(username, password string) RequestOption {
+	return func(ro *requestOptions) error {
+		ro.basicAuth = &credentials{username: username, password: password}
+
+		return nil
+	}
+}
+
+// tokenSourceAuth caches the token obtained from an oauth2.TokenSource, and replaces the source when
+// the server rejects its token so that the next attempt gets a freshly minted one.
+type tokenSourceAuth struct {
+	newSource func() oauth2.TokenSource
+
+	mu     sync.Mutex
+	source oauth2.TokenSource
+	token  *oauth2.Token
+}
+
+func (a *tokenSourceAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.token.Valid() {
+		if a.source == nil {
+			if a.source = a.newSource(); a.source == nil {
+				return fmt.Errorf("nil token source")
+			}
+		}
+		token, err := a.source.Token()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve oauth2 token: %w", err)
+		}
+		a.token = token
+	}
+	a.token.SetAuthHeader(req)
+
+	return nil
+}
+
+// OnUnauthorized discards the cached token and its source if the token is the one resp was requested
+// with, so that a new source mints a new token rather than returning the one it cached.
+func (a *tokenSourceAuth) OnUnauthorized(resp *http.Response) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != nil && resp.Request.Header.Get("Authorization") == a.token.Type()+" "+a.token.AccessToken {
+		a.token, a.source = nil, nil
+	}
+
+	return nil
+}
+
+// WithTokenSource authenticates every attempt with a Bearer token obtained from the token source
+// returned by newSource, unless the request carries its own credentials. When an attempt is rejected
+// with 401 Unauthorized, the token is discarded along with its source, and the attempt is sent again
+// with a token from a new source returned by newSource. Standard token sources cache their token, so
+// newSource must return a source that mints a new one, e.g.
+//
+//	func() oauth2.TokenSource { return cfg.TokenSource(ctx) }
+//
+// for a clientcredentials.Config.
+func WithTokenSource(newSource func() oauth2.TokenSource) ClientOption {
+	return func(c *Client) error {
+		if newSource == nil {
+			return fmt.Errorf("nil token source function")
+		}
+		c.auth = &tokenSourceAuth{newSource: newSource}
+
+		return nil
+	}
+}
+
+// answerChallenge sends req again for as long as the authenticator answers the authentication
+// challenge of the response, and returns the last response.
+func (c *Client) answerChallenge(sender *http.Client, req, send *http.Request, resp *http.Response) (*http.Response, error) {
+	responder, ok := c.auth.(challengeResponder)
+	if !ok {
+		return resp, nil
+	}
+
+	for range maxChallengeRounds {
+		answered, err := responder.respondToChallenge(req, resp)
+		if err != nil || answered {
+			// Drain the challenge so its connection is reused, as handshakes like NTLM's require.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedChallenge))
+			resp.Body.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to answer authentication challenge: %w", err)
+		}
+		if !answered {
+			return resp, nil
+		}
+
+		if err := rewindBody(req); err != nil {
+			return nil, err
+		}
+
+		// Keep the context of the original send, which may carry the attempt tracing.
+		resp, err = sender.Do(req.WithContext(send.Context()))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+// reauthenticate lets the authenticator renew the credentials refused with resp, and sends req again
+// with the new ones. It returns resp untouched if the authenticator can't renew its credentials.
+func (c *Client) reauthenticate(sender *http.Client, req, send *http.Request, resp *http.Response) (*http.Response, error) {
+	reauth, ok := c.auth.(Reauthenticator)
+	if !ok {
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedChallenge))
+	resp.Body.Close()
+	if err := reauth.OnUnauthorized(resp); err != nil {
+		return nil, fmt.Errorf("failed to reauthenticate: %w", err)
+	}
+	if err := reauth.Authenticate(req); err != nil {
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	if err := rewindBody(req); err != nil {
+		return nil, err
+	}
+
+	// Keep the context of the original send, which may carry the attempt tracing.
+	return sender.Do(req.WithContext(send.Context()))
+}
+
+// maxDrainedChallenge bounds the body read from a challenge response to reuse its connection.
+const maxDrainedChallenge = 64 << 10
@@ -1,8 +1,13 @@
 package backoffpolicy // import "github.com/condrove10/retryablehttp/backoffpolicy"
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,31 +18,312 @@ const (
 	StrategyExponential Strategy = "Exponential"
 )
 
+// ParseStrategy returns the strategy named s, matched case-insensitively, e.g. "linear" or "Exponential".
+func ParseStrategy(s string) (Strategy, error) {
+	for _, strategy := range []Strategy{StrategyLinear, StrategyExponential} {
+		if strings.EqualFold(s, string(strategy)) {
+			return strategy, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid backoff strategy '%s'", s)
+}
+
+// MarshalText implements encoding.TextMarshaler, rejecting unknown strategies.
+func (s Strategy) MarshalText() ([]byte, error) {
+	strategy, err := ParseStrategy(string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strategy), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so strategies can be read from flags,
+// environment variables and configuration files through ParseStrategy.
+func (s *Strategy) UnmarshalText(text []byte) error {
+	strategy, err := ParseStrategy(string(text))
+	if err != nil {
+		return err
+	}
+	*s = strategy
+
+	return nil
+}
+
+// Curve describes how delays grow between attempts: the first retry waits Initial, and every following
+// retry waits Multiplier times longer than the previous one. Multipliers between 1 and 2, e.g. 1.5,
+// express gentler curves than StrategyExponential.
+type Curve struct {
+	Initial    time.Duration
+	Multiplier float64
+}
+
+// StrategyCurve returns the curve followed by strategy for the given delay.
+func StrategyCurve(strategy Strategy, delay time.Duration) (Curve, error) {
+	if delay < 0 {
+		return Curve{}, fmt.Errorf("invalid delay value '%s'", delay)
+	}
+	if delay > MaxDelay {
+		return Curve{}, fmt.Errorf("invalid delay value '%s': exceeds max delay '%s'", delay, MaxDelay)
+	}
+
+	switch strategy {
+	case StrategyExponential:
+		return Curve{Initial: min(delay*2, MaxDelay), Multiplier: 2}, nil
+	case StrategyLinear:
+		return Curve{Initial: delay, Multiplier: 1}, nil
+	default:
+		return Curve{}, fmt.Errorf("invalid backoff strategy")
+	}
+}
+
+// MaxDelay caps every delay between attempts, so that long exponential sequences level off instead of
+// overflowing into sleeps lasting days.
+const MaxDelay = time.Hour
+
+// Validate reports whether the curve is usable.
+func (c Curve) Validate() error {
+	if c.Initial < 0 {
+		return fmt.Errorf("invalid backoff initial interval value '%s'", c.Initial)
+	}
+	if c.Initial > MaxDelay {
+		return fmt.Errorf("invalid backoff initial interval value '%s': exceeds max delay '%s'", c.Initial, MaxDelay)
+	}
+	if c.Multiplier < 1 || math.IsInf(c.Multiplier, 0) || math.IsNaN(c.Multiplier) {
+		return fmt.Errorf("invalid backoff multiplier value '%g'", c.Multiplier)
+	}
+
+	return nil
+}
+
+// Delay returns the delay to wait before the given attempt, which must be at least 1, capped at MaxDelay.
+func (c Curve) Delay(attempt uint32) time.Duration {
+	// Compute in floating point, where growth past the cap saturates to +Inf instead of wrapping around.
+	d := float64(c.Initial) * math.Pow(c.Multiplier, float64(attempt-1))
+	if d >= float64(MaxDelay) {
+		return MaxDelay
+	}
+
+	return time.Duration(d)
+}
+
+// Delays returns the sequence of delays waited before each retry along the curve, i.e. attempts-1
+// delays. The sequence is empty if the curve is invalid.
+func (c Curve) Delays(attempts uint32) iter.Seq[time.Duration] {
+	return func(yield func(time.Duration) bool) {
+		if c.Validate() != nil {
+			return
+		}
+
+		for attempt := uint32(1); attempt < attempts; attempt++ {
+			if !yield(c.Delay(attempt)) {
+				return
+			}
+		}
+	}
+}
+
+// Schedule gives the delays between attempts. Curve and Intervals are schedules.
+type Schedule interface {
+	// Delay returns the delay to wait before the given attempt, which must be at least 1.
+	Delay(attempt uint32) time.Duration
+	// Validate reports whether the schedule is usable.
+	Validate() error
+}
+
+// Intervals is a schedule of absolute delays, e.g. 1m, 10m, 1h, 6h and 24h: the n-th retry waits the
+// n-th interval, and retries past the last interval wait the last one. Unlike curves, intervals aren't
+// capped at MaxDelay, as they're meant for deliveries retried over days with their state persisted
+// between attempts, like those of the outbox and webhook packages.
+type Intervals []time.Duration
+
+// ParseIntervals parses a comma-separated list of durations, e.g. "1m,10m,1h,6h,24h". On top of the
+// units of time.ParseDuration, whole days can be written with the "d" unit, e.g. "2d".
+func ParseIntervals(s string) (Intervals, error) {
+	var intervals Intervals
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+
+		var (
+			d   time.Duration
+			err error
+		)
+		if days, ok := strings.CutSuffix(field, "d"); ok {
+			var n int64
+			n, err = strconv.ParseInt(days, 10, 64)
+			// Day counts past the range of a time.Duration would wrap around.
+			if err == nil && (n < 0 || n > math.MaxInt64/int64(24*time.Hour)) {
+				return nil, fmt.Errorf("invalid interval value '%s': day count out of range", field)
+			}
+			d = time.Duration(n) * 24 * time.Hour
+		} else {
+			d, err = time.ParseDuration(field)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval value '%s'", field)
+		}
+		intervals = append(intervals, d)
+	}
+
+	if err := intervals.Validate(); err != nil {
+		return nil, err
+	}
+
+	return intervals, nil
+}
+
+// Validate reports whether the intervals are usable.
+func (i Intervals) Validate() error {
+	if len(i) == 0 {
+		return fmt.Errorf("no intervals specified")
+	}
+	for _, d := range i {
+		if d < 0 {
+			return fmt.Errorf("invalid interval value '%s'", d)
+		}
+	}
+
+	return nil
+}
+
+// Delay returns the delay to wait before the given attempt, which must be at least 1.
+func (i Intervals) Delay(attempt uint32) time.Duration {
+	if len(i) == 0 {
+		return 0
+	}
+
+	return i[min(max(int(attempt), 1), len(i))-1]
+}
+
+// Delays returns the sequence of delays BackoffPolicy waits before each retry, i.e. attempts-1 delays,
+// so the same backoff curve can drive loops that don't fit the callback of BackoffPolicy. The sequence
+// is empty if strategy is invalid.
+func Delays(strategy Strategy, attempts uint32, delay time.Duration) iter.Seq[time.Duration] {
+	curve, err := StrategyCurve(strategy, delay)
+	if err != nil {
+		return func(func(time.Duration) bool) {}
+	}
+
+	return curve.Delays(attempts)
+}
+
+// ErrWouldExceedDeadline is returned by BackoffPolicyContext when waiting before the next attempt
+// would outlast the context deadline.
+var ErrWouldExceedDeadline = errors.New("next attempt would exceed deadline")
+
+// PermanentError wraps an error that stops the backoff policy without any further attempts.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that BackoffPolicy returns it immediately instead of retrying.
+// A nil error is returned unchanged.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
 func BackoffPolicy(strategy Strategy, attempts uint32, delay time.Duration, policy func(attempt uint32) error) error {
+	return BackoffPolicyContext(context.Background(), strategy, attempts, delay, policy)
+}
+
+// BackoffPolicyContext is like BackoffPolicy, but stops waiting between attempts as soon as ctx is
+// done. When ctx has a deadline that the delay before the next attempt would reach, it gives up right
+// away with ErrWouldExceedDeadline rather than sleeping past the point where the attempt could succeed.
+func BackoffPolicyContext(ctx context.Context, strategy Strategy, attempts uint32, delay time.Duration, policy func(attempt uint32) error) error {
+	return BackoffPolicyDetailed(ctx, strategy, attempts, delay, func(a Attempt) error {
+		return policy(a.Number)
+	})
+}
+
+// Attempt describes an attempt made by BackoffPolicyDetailed.
+type Attempt struct {
+	// Number is the 0-based index of the attempt.
+	Number uint32
+	// Delay is how long was waited before this attempt, zero for the first one.
+	Delay time.Duration
+	// NextDelay is how long will be waited before the next attempt if this one fails, zero for the last one.
+	NextDelay time.Duration
+	// Elapsed is the time since the first attempt started.
+	Elapsed time.Duration
+}
+
+// BackoffPolicyDetailed is like BackoffPolicyContext, but passes the details of every attempt to
+// policy, so it can take into account how long it has been retrying and the upcoming delay.
+func BackoffPolicyDetailed(ctx context.Context, strategy Strategy, attempts uint32, delay time.Duration, policy func(a Attempt) error) error {
+	curve, err := StrategyCurve(strategy, delay)
+	if err != nil {
+		return err
+	}
+
+	return BackoffPolicyCurve(ctx, curve, attempts, policy)
+}
+
+// BackoffPolicyCurve is like BackoffPolicyDetailed, but waits between attempts along curve rather than
+// a strategy.
+func BackoffPolicyCurve(ctx context.Context, curve Curve, attempts uint32, policy func(a Attempt) error) error {
 	var (
 		err     error
 		attempt uint32
-		base    uint32
+		wait    time.Duration
 	)
 
-	switch strategy {
-	case StrategyExponential:
-		base = 2
-	case StrategyLinear:
-		base = 1
-	default:
-		return fmt.Errorf("invalid backoff strategy")
+	if err := curve.Validate(); err != nil {
+		return err
+	}
+	if attempts == 0 {
+		return fmt.Errorf("invalid attempts value '%d'", attempts)
+	}
+	if policy == nil {
+		return fmt.Errorf("nil backoff policy function")
 	}
 
+	start := time.Now()
 	for ; attempt < attempts; attempt++ {
 		if attempt > 0 {
-			time.Sleep(delay * time.Duration(math.Pow(float64(base), float64(attempt))))
+			wait = curve.Delay(attempt)
+
+			if deadline, ok := ctx.Deadline(); ok {
+				if left := time.Until(deadline); wait >= left {
+					return fmt.Errorf("backoff policy aborted: %w (delay %s, %s left): %w", ErrWouldExceedDeadline, wait, left.Round(time.Millisecond), err)
+				}
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("backoff policy interrupted: %w: %w", context.Cause(ctx), err)
+			}
+		}
+
+		a := Attempt{Number: attempt, Delay: wait, Elapsed: time.Since(start)}
+		if attempt+1 < attempts {
+			a.NextDelay = curve.Delay(attempt + 1)
 		}
 
-		err = policy(attempt)
+		err = policy(a)
 		if err == nil {
 			return nil
 		}
+
+		var permanent *PermanentError
+		if errors.As(err, &permanent) {
+			return fmt.Errorf("backoff policy aborted: %w", err)
+		}
 	}
 
 	return fmt.Errorf("backoff policy exhausted: %w", err)
@@ -0,0 +1,87 @@
+package retryablehttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ErrorClassDNS matches DNS resolution failures, except for non-existent domains.
+	ErrorClassDNS ErrorClass = "dns"
+	// ErrorClassDNSNotFound matches DNS resolution failures for non-existent domains.
+	ErrorClassDNSNotFound ErrorClass = "dns-not-found"
+	// ErrorClassTLSCertificate matches failures to verify the certificate of the server. TLS handshake
+	// timeouts are matched by ErrorClassTimeout instead.
+	ErrorClassTLSCertificate ErrorClass = "tls-certificate"
+	// ErrorClassConnectionReset matches connections reset or closed by the server mid-request,
+	// including reused keep-alive connections the server had already closed.
+	ErrorClassConnectionReset ErrorClass = "connection-reset"
+)
+
+// ClassifyError returns the most specific class of an attempt error: ErrorClassDNSNotFound,
+// ErrorClassDNS, ErrorClassTLSCertificate, ErrorClassConnectionReset, ErrorClassTimeout or
+// ErrorClassNetwork. It returns an empty class for nil errors and
+// cancelled requests.
+func ClassifyError(err error) ErrorClass {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return ErrorClassDNSNotFound
+		}
+		return ErrorClassDNS
+	}
+
+	if isCertificateError(err) {
+		return ErrorClassTLSCertificate
+	}
+
+	if isConnectionReset(err) {
+		return ErrorClassConnectionReset
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrBodyReadTimeout) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorClassTimeout
+	}
+
+	return ErrorClassNetwork
+}
+
+// IsPermanentError reports whether an attempt error is known to persist on retry, i.e. a non-existent
+// domain or an invalid server certificate, so the default policies fail fast on it.
+func IsPermanentError(err error) bool {
+	class := ClassifyError(err)
+
+	return class == ErrorClassDNSNotFound || class == ErrorClassTLSCertificate
+}
+
+// isConnectionReset reports whether err is a connection reset or closed by the server.
+func isConnectionReset(err error) bool {
+	// net/http doesn't export the error of reused connections closed by the server.
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		strings.Contains(err.Error(), "http: server closed idle connection")
+}
+
+// isCertificateError reports whether err is a failure to verify a certificate.
+func isCertificateError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		authorityErr    x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		invalidErr      x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &verificationErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
@@ -0,0 +1,33 @@
+package retryablehttp
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrClientClosed is returned for requests made after the client has been closed.
+var ErrClientClosed = errors.New("client closed")
+
+// lifecycle tracks whether the client has been closed and signals its background goroutines.
+type lifecycle struct {
+	closed atomic.Bool
+	done   chan struct{}
+}
+
+// Close closes the idle connections of the underlying http.Client, stops the client's background
+// goroutines and renders the client unusable: later requests fail with ErrClientClosed. Requests
+// already in progress are left to complete. Queued audit records are delivered before Close returns.
+// Closing an already closed client is a no-op.
+func (c *Client) Close() error {
+	if !c.lifecycle.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	close(c.lifecycle.done)
+	c.httpClient.CloseIdleConnections()
+	if c.audit != nil {
+		<-c.audit.done
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Codec converts Go values to and from the bodies of a media type.
+type Codec interface {
+	// ContentType returns the media type handled by the codec, e.g. "application/json".
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values as JSON. It's the default codec for request bodies.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string                { return "application/json" }
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// XMLCodec encodes values as XML.
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string                { return "application/xml" }
+func (XMLCodec) Marshal(v any) ([]byte, error)      { return xml.Marshal(v) }
+func (XMLCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+// defaultCodecs returns the codecs every client starts with, by media type.
+func defaultCodecs() map[string]Codec {
+	return map[string]Codec{
+		"application/json": JSONCodec{},
+		"application/xml":  XMLCodec{},
+		"text/xml":         XMLCodec{},
+	}
+}
+
+// WithCodec registers codec for its media type, replacing any codec registered for it.
+func WithCodec(codec Codec) ClientOption {
+	return func(c *Client) error {
+		if codec == nil {
+			return fmt.Errorf("nil codec")
+		}
+		mediaType, _, err := mime.ParseMediaType(codec.ContentType())
+		if err != nil {
+			return fmt.Errorf("invalid codec content type '%s': %w", codec.ContentType(), err)
+		}
+		c.codecs[mediaType] = codec
+
+		return nil
+	}
+}
+
+// codecFor returns the codec registered for the media type of contentType.
+func (c *Client) codecFor(contentType string) (Codec, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type '%s': %w", contentType, err)
+	}
+	codec, ok := c.codecs[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for content type '%s'", mediaType)
+	}
+
+	return codec, nil
+}
+
+// encodeBody returns a reader over body, which is sent as is when it's a []byte, a string or an
+// io.Reader, and marshalled with the codec of the Content-Type in header otherwise. Without a
+// Content-Type, values are encoded as JSON and the header is set accordingly.
+func (c *Client) encodeBody(body any, header http.Header) (io.Reader, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return bytes.NewReader(b), nil
+	case string:
+		return strings.NewReader(b), nil
+	case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+		return body.(io.Reader), nil
+	case io.Reader:
+		return readerSection(b), nil
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = JSONCodec{}.ContentType()
+		header.Set("Content-Type", contentType)
+	}
+
+	codec, err := c.codecFor(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := codec.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// Decode reads and closes the body of resp, and unmarshals it into v with the codec registered
+// for the response Content-Type. Responses without a Content-Type are decoded as JSON.
+func (c *Client) Decode(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = JSONCodec{}.ContentType()
+	}
+
+	codec, err := c.codecFor(contentType)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := codec.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return nil
+}
+
+// readerSection returns the rest of r as a section when it can be read at offsets, such as a file, so it's
+// sent with its length and replayed without being read into memory. Other readers are returned as is.
+func readerSection(r io.Reader) io.Reader {
+	rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return r
+	}
+
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return r
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if _, seekErr := rs.Seek(offset, io.SeekStart); err != nil || seekErr != nil {
+		return r
+	}
+
+	return io.NewSectionReader(rs, offset, end-offset)
+}
+
+// setSectionBody sends the section s as the body of req, with its length, and replays it with new
+// sections reading from the same offsets.
+func setSectionBody(req *http.Request, s *io.SectionReader) {
+	req.ContentLength = s.Size()
+	if req.ContentLength == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+
+	req.Body = io.NopCloser(s)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(s, 0, s.Size())), nil
+	}
+}
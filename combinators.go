@@ -0,0 +1,76 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// Chain combines policies that must all accept an outcome: they're evaluated in order, and the first
+// one rejecting the outcome decides whether it's retried.
+func Chain(policies ...Policy) Policy {
+	return func(resp *http.Response, err error) error {
+		for _, p := range policies {
+			if perr := p(resp, err); perr != nil {
+				return perr
+			}
+		}
+
+		return nil
+	}
+}
+
+// FallbackTo follows policy, but when it doesn't call for a retry, i.e. accepts an outcome or gives
+// up on it, the fallbacks are consulted in order and the first one calling for a retry takes over.
+// Otherwise the outcome of policy stands.
+func FallbackTo(policy Policy, fallbacks ...Policy) Policy {
+	return func(resp *http.Response, err error) error {
+		perr := policy(resp, err)
+		if perr != nil && !isPermanent(perr) {
+			return perr
+		}
+
+		for _, fallback := range fallbacks {
+			if ferr := fallback(resp, err); ferr != nil && !isPermanent(ferr) {
+				return ferr
+			}
+		}
+
+		return perr
+	}
+}
+
+// LimitRetriesFor allows at most n retries of responses with one of the given status codes within a
+// request, so e.g. 429 responses don't consume every attempt. Other outcomes are accepted, so it's
+// meant to be chained in front of the policy deciding the retries:
+//
+//	Chain(
+//		LimitRetriesFor([]int{http.StatusTooManyRequests}, 2),
+//		RetryIf(Any(RetryOn5xx, RetryOnStatuses(http.StatusTooManyRequests))),
+//	)
+func LimitRetriesFor(statuses []int, n uint32) Policy {
+	return func(resp *http.Response, err error) error {
+		if err != nil || resp == nil || !slices.Contains(statuses, resp.StatusCode) {
+			return nil
+		}
+
+		info, ok := RetryInfoFromResponse(resp)
+		if !ok {
+			return nil
+		}
+
+		var seen uint32
+		for _, status := range info.Statuses {
+			if slices.Contains(statuses, status) {
+				seen++
+			}
+		}
+		if seen <= n {
+			return nil
+		}
+
+		return backoffpolicy.Permanent(fmt.Errorf("HTTP response status code (%d) retried %d times, limit reached", resp.StatusCode, n))
+	}
+}
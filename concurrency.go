@@ -0,0 +1,233 @@
+package retryablehttp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimited reports an attempt shed by WithAdaptiveConcurrency because too many attempts
+// were already waiting for the concurrency limit of their host.
+var ErrConcurrencyLimited = errors.New("concurrency limit reached")
+
+// concurrencyBaselineWindow is how long the minimum latency of a host is kept as its baseline. The
+// baseline is the minimum over the current and the previous window, so it follows the upstream when
+// its latency shifts for good.
+const concurrencyBaselineWindow = 30 * time.Second
+
+// AdaptiveConcurrency configures the discovery of the parallelism each host sustains, see
+// WithAdaptiveConcurrency. Zero-valued fields select their default.
+type AdaptiveConcurrency struct {
+	// InitialLimit is the limit a host starts with. It defaults to 10.
+	InitialLimit uint32
+	// MinLimit and MaxLimit bound the limit. They default to 1 and 1000.
+	MinLimit uint32
+	MaxLimit uint32
+	// LatencyTolerance is the multiple of the baseline latency of a host, the lowest observed recently,
+	// above which an attempt signals congestion, e.g. 2. It defaults to 2.
+	LatencyTolerance float64
+	// Backoff is the factor the limit is multiplied by on congestion, between 0 and 1. It defaults to 0.9.
+	Backoff float64
+	// MaxQueue is the number of attempts that may wait for a slot of a host, beyond which attempts are
+	// shed with ErrConcurrencyLimited. Zero lets every attempt wait.
+	MaxQueue uint32
+}
+
+// WithAdaptiveConcurrency limits the number of attempts in flight to each host to a limit discovered
+// from the upstream behaviour rather than set up front (additive increase, multiplicative decrease):
+// while the limit is in use, it grows by one for every limit's worth of attempts completing without
+// congestion, i.e. about once per round trip, and shrinks by the Backoff factor when an attempt signals
+// congestion: its latency exceeds the tolerance, it times out or it's rejected with 429 Too Many
+// Requests or 503 Service Unavailable.
+// Attempts beyond the limit wait for a free slot, or are shed once MaxQueue attempts are waiting; shed
+// attempts fail the request without retrying. A slot is held until the response body is closed. It
+// applies on top of WithMaxConcurrentRequests and WithMaxConcurrentRequestsPerHost.
+func WithAdaptiveConcurrency(cfg AdaptiveConcurrency) ClientOption {
+	return func(c *Client) error {
+		if cfg.MinLimit == 0 {
+			cfg.MinLimit = 1
+		}
+		if cfg.MaxLimit == 0 {
+			cfg.MaxLimit = 1000
+		}
+		if cfg.InitialLimit == 0 {
+			cfg.InitialLimit = min(max(10, cfg.MinLimit), cfg.MaxLimit)
+		}
+		if cfg.LatencyTolerance == 0 {
+			cfg.LatencyTolerance = 2
+		}
+		if cfg.Backoff == 0 {
+			cfg.Backoff = 0.9
+		}
+
+		if cfg.MinLimit > cfg.MaxLimit {
+			return fmt.Errorf("invalid adaptive concurrency limits '%d' to '%d'", cfg.MinLimit, cfg.MaxLimit)
+		}
+		if cfg.InitialLimit < cfg.MinLimit || cfg.InitialLimit > cfg.MaxLimit {
+			return fmt.Errorf("invalid adaptive concurrency initial limit value '%d'", cfg.InitialLimit)
+		}
+		if cfg.LatencyTolerance <= 1 {
+			return fmt.Errorf("invalid adaptive concurrency latency tolerance value '%g'", cfg.LatencyTolerance)
+		}
+		if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+			return fmt.Errorf("invalid adaptive concurrency backoff value '%g'", cfg.Backoff)
+		}
+		c.aimdLimits = &adaptiveConcurrency{cfg: cfg, hosts: map[string]*aimdLimiter{}}
+
+		return nil
+	}
+}
+
+// adaptiveConcurrency lazily creates one AIMD limiter per host, and drops those of the hosts left idle.
+type adaptiveConcurrency struct {
+	cfg AdaptiveConcurrency
+
+	mu      sync.Mutex
+	hosts   map[string]*aimdLimiter
+	sweeper idleSweeper
+}
+
+func (a *adaptiveConcurrency) get(host string) *aimdLimiter {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sweeper.due(now) {
+		for key, l := range a.hosts {
+			if l.idle(now) {
+				delete(a.hosts, key)
+			}
+		}
+	}
+
+	l, ok := a.hosts[host]
+	if !ok {
+		l = &aimdLimiter{cfg: &a.cfg, limit: float64(a.cfg.InitialLimit), waiters: list.New()}
+		a.hosts[host] = l
+	}
+	l.mu.Lock()
+	l.used = now
+	l.mu.Unlock()
+
+	return l
+}
+
+// congested reports whether the outcome of an attempt signals that its host is overloaded.
+func congested(resp *http.Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	}
+
+	return ClassifyError(err) == ErrorClassTimeout
+}
+
+// aimdLimiter is a semaphore whose limit follows the congestion signals of its host.
+type aimdLimiter struct {
+	cfg *AdaptiveConcurrency
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight uint32
+	waiters  *list.List
+
+	baseline, previousBaseline time.Duration
+	baselineStart              time.Time
+	// used is when the limiter was last handed out for an attempt.
+	used time.Time
+}
+
+// idle reports whether no attempt holds or waits for a slot of l, and none was sent for
+// hostIdleTimeout at now. Its limit is then relearned from the initial one.
+func (l *aimdLimiter) idle(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight == 0 && l.waiters.Len() == 0 && now.Sub(l.used) > hostIdleTimeout
+}
+
+// acquire takes a slot, waiting in line for one to be free if the limit is reached.
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight < uint32(l.limit) && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.cfg.MaxQueue > 0 && uint32(l.waiters.Len()) >= l.cfg.MaxQueue {
+		l.mu.Unlock()
+		return ErrConcurrencyLimited
+	}
+	ready := make(chan struct{})
+	waiter := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		select {
+		case <-ready:
+			// The slot was handed over in the meantime: give it to the next in line.
+			l.inFlight--
+			l.grant()
+		default:
+			l.waiters.Remove(waiter)
+		}
+
+		return ctx.Err()
+	}
+}
+
+// release frees a slot.
+func (l *aimdLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.grant()
+}
+
+// grant hands the free slots over to the waiting attempts. l.mu must be held.
+func (l *aimdLimiter) grant() {
+	for l.inFlight < uint32(l.limit) && l.waiters.Len() > 0 {
+		ready := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.inFlight++
+		close(ready)
+	}
+}
+
+// record adjusts the limit to the outcome of an attempt that took latency.
+func (l *aimdLimiter) record(latency time.Duration, congested bool, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.baselineStart) > concurrencyBaselineWindow {
+		l.previousBaseline, l.baseline, l.baselineStart = l.baseline, 0, now
+	}
+	if l.baseline == 0 || latency < l.baseline {
+		l.baseline = latency
+	}
+	baseline := l.baseline
+	if l.previousBaseline > 0 {
+		baseline = min(baseline, l.previousBaseline)
+	}
+
+	switch {
+	case congested || float64(latency) > l.cfg.LatencyTolerance*float64(baseline):
+		l.limit = max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+	case float64(l.inFlight)*2 >= l.limit:
+		// Only grow a limit that's in use, or it would grow without bound under light load.
+		l.limit = min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+		l.grant()
+	}
+}
@@ -0,0 +1,50 @@
+package retryablehttp
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+)
+
+// ErrRetryDropped reports a request failed without retrying because too many requests of the client
+// were retrying already, see WithRetryDamping.
+var ErrRetryDropped = errors.New("retry dropped by retry storm damping")
+
+// minDampingRequests is the number of requests in progress below which retries are never dropped, as
+// the share of retrying requests means little on few requests.
+const minDampingRequests = 10
+
+// retryDamping drops retries while the share of requests retrying is above threshold.
+type retryDamping struct {
+	threshold float64
+}
+
+// WithRetryDamping protects the client and its upstreams from retry storms: while the share of the
+// requests in progress that are retrying exceeds threshold (0 to 1, exclusive), new retries are dropped
+// with a probability growing linearly from 0 at the threshold to 1 when every request is retrying, and
+// their requests fail right away with ErrRetryDropped. Retries are never dropped with fewer than 10
+// requests in progress.
+func WithRetryDamping(threshold float64) ClientOption {
+	return func(c *Client) error {
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("invalid retry damping threshold '%g'", threshold)
+		}
+		c.retryDamping = &retryDamping{threshold: threshold}
+
+		return nil
+	}
+}
+
+// drop reports whether to drop a retry, given the number of requests retrying and in progress.
+func (d *retryDamping) drop(retrying, inFlight int64) bool {
+	if inFlight < minDampingRequests {
+		return false
+	}
+
+	share := float64(retrying) / float64(inFlight)
+	if share <= d.threshold {
+		return false
+	}
+
+	return rand.Float64() < (share-d.threshold)/(1-d.threshold)
+}
@@ -0,0 +1,231 @@
+package retryablehttp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestChallenge is a Digest challenge from a WWW-Authenticate header, with the number of times its
+// nonce was used.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	count     uint32
+}
+
+// digestAuth authenticates requests with HTTP Digest authentication (RFC 7616), answering the last
+// challenge received from each host.
+type digestAuth struct {
+	username string
+	password string
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge
+}
+
+// WithDigestAuth authenticates requests with HTTP Digest authentication (RFC 7616), unless the request
+// carries its own credentials. Attempts rejected with a Digest challenge are answered and sent again
+// right away, without consuming a retry; the challenge is then reused for the next requests to the
+// same host. The MD5 and SHA-256 algorithms, their session variants and the "auth" and "auth-int"
+// protection qualities are supported.
+func WithDigestAuth(username, password string) ClientOption {
+	return WithAuthenticator(&digestAuth{username: username, password: password, challenges: map[string]*digestChallenge{}})
+}
+
+func (a *digestAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	ch, ok := a.challenges[req.URL.Host]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	ch.count++
+	challenge := *ch
+	a.mu.Unlock()
+
+	authorization, err := a.authorization(req, &challenge)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	return nil
+}
+
+// respondToChallenge stores the Digest challenge of resp for the next requests, and answers it on req
+// unless req already answered a challenge whose nonce the server doesn't report as stale, so that wrong
+// credentials aren't resent in a loop.
+func (a *digestAuth) respondToChallenge(req *http.Request, resp *http.Response) (bool, error) {
+	var best *digestChallenge
+	stale := false
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		scheme, params, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		p := parseAuthParams(params)
+		ch := &digestChallenge{
+			realm:     p["realm"],
+			nonce:     p["nonce"],
+			opaque:    p["opaque"],
+			algorithm: strings.ToUpper(p["algorithm"]),
+			userhash:  strings.EqualFold(p["userhash"], "true"),
+		}
+		if ch.algorithm == "" {
+			ch.algorithm = "MD5"
+		}
+		if digestHash(ch.algorithm) == nil || ch.nonce == "" {
+			continue
+		}
+		for _, qop := range strings.Split(p["qop"], ",") {
+			switch qop = strings.TrimSpace(qop); {
+			case qop == "auth":
+				ch.qop = qop
+			case qop == "auth-int" && ch.qop == "":
+				ch.qop = qop
+			}
+		}
+		if p["qop"] != "" && ch.qop == "" {
+			continue
+		}
+
+		// Prefer SHA-256 over MD5, as servers offer both for compatibility.
+		if best == nil || strings.HasPrefix(ch.algorithm, "SHA-256") {
+			best, stale = ch, strings.EqualFold(p["stale"], "true")
+		}
+	}
+	if best == nil {
+		return false, nil
+	}
+
+	a.mu.Lock()
+	a.challenges[req.URL.Host] = best
+	a.mu.Unlock()
+
+	if strings.HasPrefix(req.Header.Get("Authorization"), "Digest ") && !stale {
+		return false, nil
+	}
+
+	return true, a.Authenticate(req)
+}
+
+// authorization returns the Authorization header answering challenge for req.
+func (a *digestAuth) authorization(req *http.Request, ch *digestChallenge) (string, error) {
+	h := func(s string) string {
+		hash := digestHash(ch.algorithm)
+		hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate digest cnonce: %w", err)
+	}
+	cnonce := hex.EncodeToString(b[:])
+	nc := fmt.Sprintf("%08x", ch.count)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(a.username + ":" + ch.realm + ":" + a.password)
+	if strings.HasSuffix(ch.algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + ch.nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	if ch.qop == "auth-int" {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return "", err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + h(string(body)))
+	}
+
+	response := h(ha1 + ":" + ch.nonce + ":" + ha2)
+	if ch.qop != "" {
+		response = h(ha1 + ":" + ch.nonce + ":" + nc + ":" + cnonce + ":" + ch.qop + ":" + ha2)
+	}
+
+	username := a.username
+	if ch.userhash {
+		username = h(a.username + ":" + ch.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", ch.realm),
+		fmt.Sprintf("nonce=%q", ch.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + ch.algorithm,
+		fmt.Sprintf("response=%q", response),
+	}
+	if ch.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", ch.opaque))
+	}
+	if ch.qop != "" {
+		params = append(params, "qop="+ch.qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if ch.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// digestHash returns a new hash for a Digest algorithm, or nil if the algorithm isn't supported.
+func digestHash(algorithm string) hash.Hash {
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// parseAuthParams parses the comma-separated parameters of an authentication challenge, whose values
+// may be quoted strings containing commas.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			value, rest = b.String(), rest[min(i+1, len(rest)):]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+			rest = "," + rest
+		}
+		params[key] = value
+
+		_, rest, _ = strings.Cut(rest, ",")
+		s = rest
+	}
+
+	return params
+}
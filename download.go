@@ -0,0 +1,136 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// GetInto sends a GET request with the retry logic of c and streams the response body to w, without
+// holding it in memory, returning the number of bytes written. When the connection drops mid-body, the
+// download is resumed where it stopped with a Range request, after waiting according to the client
+// backoff strategy, at most as many times as the client attempts. Resumed responses are bound to the
+// first one by its ETag or Last-Modified header with If-Range, and the download fails if the resource
+// changed in between; servers ignoring ranges have the part already written skipped. Failures to write
+// to w aren't retried. Compression is disabled, so the bytes written are those stored by the server.
+func (c *Client) GetInto(url string, w io.Writer, headers map[string]string, opts ...RequestOption) (int64, error) {
+	for k := range headers {
+		if k := http.CanonicalHeaderKey(k); k == "Range" || k == "If-Range" {
+			return 0, &ConfigError{Err: fmt.Errorf("invalid header '%s': ranges are managed by the download", k)}
+		}
+	}
+	// Asking for the identity encoding also keeps net/http from decompressing the body, which would make
+	// byte offsets meaningless.
+	headers = withDefaultHeader(headers, "Accept-Encoding", "identity")
+
+	ro, err := newRequestOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	settings := c.defaultSettings()
+	curve, err := settings.backoffCurve()
+	if err != nil {
+		return 0, err
+	}
+
+	// Bound the resumptions and the waits between them as execute bounds attempts.
+	ctx, cancel := c.requestContext(ro.ctx)
+	defer cancel()
+
+	dst := &downloadWriter{w: w}
+	var validator string
+	err = backoffpolicy.BackoffPolicyCurve(ctx, curve, settings.attempts, func(a backoffpolicy.Attempt) error {
+		attemptHeaders := headers
+		if dst.written > 0 {
+			attemptHeaders = withDefaultHeader(headers, "Range", fmt.Sprintf("bytes=%d-", dst.written))
+			if validator != "" {
+				attemptHeaders["If-Range"] = validator
+			}
+		}
+
+		// Opening the download is already retried by the client.
+		resp, err := c.Get(url, attemptHeaders, opts...)
+		if err != nil {
+			return backoffpolicy.Permanent(err)
+		}
+		defer resp.Body.Close()
+
+		body := io.Reader(resp.Body)
+		switch {
+		case dst.written == 0:
+			validator = rangeValidator(resp)
+		case resp.StatusCode == http.StatusPartialContent:
+			if start, ok := contentRangeStart(resp); !ok || start != dst.written {
+				return backoffpolicy.Permanent(fmt.Errorf("unexpected content range '%s' resuming download at byte %d", resp.Header.Get("Content-Range"), dst.written))
+			}
+		case validator != "" && rangeValidator(resp) != validator:
+			return backoffpolicy.Permanent(fmt.Errorf("resource changed while resuming download at byte %d", dst.written))
+		default:
+			// Without range support, the whole resource is sent again: skip the part that was already written.
+			if _, err := io.CopyN(io.Discard, body, dst.written); err != nil {
+				return fmt.Errorf("download interrupted: %w", err)
+			}
+		}
+
+		if _, err := io.Copy(dst, body); err != nil {
+			if dst.err != nil {
+				return backoffpolicy.Permanent(fmt.Errorf("failed to write download: %w", dst.err))
+			}
+			return fmt.Errorf("download interrupted at byte %d: %w", dst.written, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return dst.written, fmt.Errorf("failed to download '%s': %w", url, err)
+	}
+
+	return dst.written, nil
+}
+
+// downloadWriter counts the bytes written to w, and keeps the error of w apart from those of the body.
+type downloadWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.written += int64(n)
+	if err != nil {
+		d.err = err
+	}
+
+	return n, err
+}
+
+// rangeValidator returns the If-Range value binding resumed requests to resp: its ETag if strong, or
+// else its Last-Modified date, or an empty string if it has neither.
+func rangeValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
+
+// contentRangeStart returns the first byte position of the Content-Range of resp, e.g. 100 for
+// "bytes 100-999/1000".
+func contentRangeStart(resp *http.Response) (int64, bool) {
+	rest, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+
+	return start, err == nil
+}
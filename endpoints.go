@@ -0,0 +1,256 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// endpoint is one of the equivalent base URLs serving the same API.
+type endpoint struct {
+	scheme       string
+	host         string
+	healthy      atomic.Bool
+	weight       atomic.Uint32
+	ejectedUntil atomic.Int64
+	outlier      outlierState
+}
+
+func (e *endpoint) String() string {
+	return e.scheme + "://" + e.host
+}
+
+// endpointPool spreads requests across endpoints and fails attempts over between them.
+type endpointPool struct {
+	endpoints        []*endpoint
+	next             atomic.Uint64
+	outlierDetection *OutlierDetection
+	hostStats        *hostStatsTracker
+
+	mu sync.Mutex
+}
+
+// contains reports whether u targets one of the endpoints.
+func (p *endpointPool) contains(u *url.URL) bool {
+	for _, e := range p.endpoints {
+		if strings.EqualFold(u.Scheme, e.scheme) && strings.EqualFold(u.Host, e.host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// start returns the rotation offset of a new request, so requests are spread across endpoints.
+func (p *endpointPool) start() uint64 {
+	return p.next.Add(1) - 1
+}
+
+// open returns the number of endpoints out of rotation at now, i.e. down or ejected as outliers. A nil
+// pool has none.
+func (p *endpointPool) open(now time.Time) int {
+	if p == nil {
+		return 0
+	}
+
+	n := 0
+	for _, e := range p.endpoints {
+		if !e.healthy.Load() || e.ejected(now) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// pick returns the endpoint for an attempt of a request. The first attempt picks an endpoint in
+// proportion to the endpoint weights, and every retry moves on to the next endpoint. Endpoints known
+// to be down, ejected as outliers or weighted zero are skipped, unless every endpoint is.
+func (p *endpointPool) pick(start uint64, attempt uint32) *endpoint {
+	now := time.Now()
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	weights := make([]uint64, 0, len(p.endpoints))
+	var total uint64
+	for _, e := range p.endpoints {
+		if weight := uint64(e.weight.Load()); weight > 0 && e.healthy.Load() && !e.ejected(now) {
+			candidates = append(candidates, e)
+			weights = append(weights, weight)
+			total += weight
+		}
+	}
+	if len(candidates) == 0 {
+		return p.endpoints[(start+uint64(attempt))%uint64(len(p.endpoints))]
+	}
+
+	// Map the rotation offset onto the cumulative weights to find the first endpoint.
+	first := 0
+	for offset := start % total; offset >= weights[first]; first++ {
+		offset -= weights[first]
+	}
+
+	return candidates[(uint64(first)+uint64(attempt))%uint64(len(candidates))]
+}
+
+// lookup returns the endpoint with the given base URL.
+func (p *endpointPool) lookup(raw string) (*endpoint, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint '%s': %w", raw, err)
+	}
+	for _, e := range p.endpoints {
+		if strings.EqualFold(u.Scheme, e.scheme) && strings.EqualFold(u.Host, e.host) {
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown endpoint '%s'", raw)
+}
+
+// WithEndpoints configures equivalent base URLs (scheme and host, e.g. "https://eu.api.example.com")
+// serving the same API. Requests whose URL targets one of them are spread across all of them, and
+// every retry moves on to the next endpoint, so a failing endpoint doesn't consume every attempt.
+func WithEndpoints(endpoints ...string) ClientOption {
+	return func(c *Client) error {
+		if len(endpoints) == 0 {
+			return fmt.Errorf("no endpoints specified")
+		}
+
+		pool := &endpointPool{}
+		for _, raw := range endpoints {
+			u, err := url.Parse(raw)
+			if err != nil {
+				return fmt.Errorf("invalid endpoint '%s': %w", raw, err)
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid endpoint '%s': expected an http or https base url", raw)
+			}
+			if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+				return fmt.Errorf("invalid endpoint '%s': paths and queries aren't supported", raw)
+			}
+
+			e := &endpoint{scheme: u.Scheme, host: u.Host}
+			e.healthy.Store(true)
+			e.weight.Store(1)
+			pool.endpoints = append(pool.endpoints, e)
+		}
+		c.endpoints = pool
+
+		return nil
+	}
+}
+
+// WithEndpointWeight sets the share of requests sent first to an endpoint configured with WithEndpoints,
+// relative to the weights of the other endpoints, e.g. 90 and 10 for a canary traffic split. Endpoints
+// weigh 1 by default; a zero weight drains the endpoint, which is then only used if every other endpoint
+// is unavailable.
+func WithEndpointWeight(endpoint string, weight uint32) ClientOption {
+	return func(c *Client) error {
+		c.endpointWeights[endpoint] = weight
+
+		return nil
+	}
+}
+
+// SetEndpointWeight changes the weight of an endpoint configured with WithEndpoints while the client
+// is in use. See WithEndpointWeight.
+func (c *Client) SetEndpointWeight(endpoint string, weight uint32) error {
+	if c.endpoints == nil {
+		return fmt.Errorf("no endpoints configured")
+	}
+
+	e, err := c.endpoints.lookup(endpoint)
+	if err != nil {
+		return err
+	}
+	e.weight.Store(weight)
+
+	return nil
+}
+
+// routeToEndpoint points req at the endpoint selected for the attempt.
+func routeToEndpoint(req *http.Request, e *endpoint) {
+	req.URL.Scheme = e.scheme
+	req.URL.Host = e.host
+	req.Host = ""
+}
+
+// healthCheck holds the configuration of the active endpoint health checks.
+type healthCheck struct {
+	path     string
+	interval time.Duration
+}
+
+// WithHealthCheck actively checks the endpoints configured with WithEndpoints by sending a GET
+// request for path to each of them every interval. Endpoints that fail to answer with a 2xx status
+// within the interval are skipped by requests until they recover; if every endpoint is down, requests
+// are still attempted against all of them. Checks stop when the client is closed.
+func WithHealthCheck(path string, interval time.Duration) ClientOption {
+	return func(c *Client) error {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid health check path '%s': must start with '/'", path)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("invalid health check interval value '%s'", interval)
+		}
+		c.healthCheck = &healthCheck{path: path, interval: interval}
+
+		return nil
+	}
+}
+
+// runHealthChecks checks every endpoint periodically until the client is closed or its context is done.
+func (c *Client) runHealthChecks() {
+	ticker := time.NewTicker(c.healthCheck.interval)
+	defer ticker.Stop()
+
+	for {
+		c.checkEndpoints()
+
+		select {
+		case <-ticker.C:
+		case <-c.lifecycle.done:
+			return
+		case <-c.context.Done():
+			return
+		}
+	}
+}
+
+// checkEndpoints checks every endpoint concurrently and records their health.
+func (c *Client) checkEndpoints() {
+	var wg sync.WaitGroup
+	for _, e := range c.endpoints.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.healthy.Store(c.checkEndpoint(e))
+		}()
+	}
+	wg.Wait()
+}
+
+// checkEndpoint reports whether e answers its health check with a 2xx status.
+func (c *Client) checkEndpoint(e *endpoint) bool {
+	ctx, cancel := context.WithTimeout(c.context, c.healthCheck.interval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.String()+c.healthCheck.path, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode >= 200 && resp.StatusCode <= 299
+}
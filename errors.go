@@ -0,0 +1,67 @@
+package retryablehttp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// Errors classifying why a request failed, matched with errors.Is on the error returned by the client.
+var (
+	// ErrCanceled reports that the request or client context was cancelled.
+	ErrCanceled = errors.New("request canceled")
+	// ErrDeadlineExceeded reports that the request deadline expired, or that the next attempt
+	// couldn't have completed before it.
+	ErrDeadlineExceeded = errors.New("request deadline exceeded")
+	// ErrAttemptsExhausted reports that every attempt failed.
+	ErrAttemptsExhausted = errors.New("retry attempts exhausted")
+	// ErrRetriesAborted reports that retries were stopped before using every attempt, e.g. because
+	// the policy rejected an outcome permanently.
+	ErrRetriesAborted = errors.New("retries aborted")
+)
+
+// RetryError is returned when a request fails. Kind holds one of ErrCanceled, ErrDeadlineExceeded,
+// ErrAttemptsExhausted or ErrRetriesAborted, and both Kind and Err match errors.Is and errors.As.
+type RetryError struct {
+	Kind     error
+	Attempts uint32
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ConfigError reports a request that couldn't be built, e.g. because of an invalid method or URL, a
+// body that failed to encode or a failing body provider. It's returned as is, without retrying.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// newRetryError classifies the failure err of a request with context ctx after the given attempts.
+func newRetryError(ctx context.Context, attempts uint32, err error) *RetryError {
+	kind := ErrAttemptsExhausted
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, backoffpolicy.ErrWouldExceedDeadline):
+		kind = ErrDeadlineExceeded
+	case ctx.Err() != nil:
+		kind = ErrCanceled
+	case isPermanent(err):
+		kind = ErrRetriesAborted
+	}
+
+	return &RetryError{Kind: kind, Attempts: attempts, Err: err}
+}
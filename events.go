@@ -0,0 +1,103 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AttemptEventType identifies the kind of an AttemptEvent.
+type AttemptEventType string
+
+const (
+	// EventAttemptStart is emitted right before an attempt is sent.
+	EventAttemptStart AttemptEventType = "start"
+	// EventAttemptResponse is emitted once an attempt completed, with a response or an error.
+	EventAttemptResponse AttemptEventType = "response"
+	// EventRetryScheduled is emitted when a failed attempt is going to be retried.
+	EventRetryScheduled AttemptEventType = "retry-scheduled"
+	// EventExhausted is emitted when a request failed and no more attempts will be made.
+	EventExhausted AttemptEventType = "exhausted"
+)
+
+// AttemptEvent describes a step in the lifecycle of a request.
+type AttemptEvent struct {
+	Type AttemptEventType
+	Time time.Time
+	// RequestID is the ID assigned to the request, if request IDs are enabled.
+	RequestID string
+	Method    string
+	// URL is the URL of the request with its password and query values masked, as in the logs.
+	URL string
+	// Attempt is the zero-based index of the attempt the event relates to.
+	Attempt uint32
+	// StatusCode is the status of the attempt response, or 0 if there's none.
+	StatusCode int
+	// Err is the error that failed the attempt or the request, if any.
+	Err error
+}
+
+// WithEventChannel emits an AttemptEvent to ch for every step of each request. Events are sent
+// without blocking: when ch isn't ready to receive, the event is dropped, so a slow consumer never
+// delays requests. Use a buffered channel to avoid losing events in bursts.
+func WithEventChannel(ch chan<- AttemptEvent) ClientOption {
+	return func(c *Client) error {
+		if ch == nil {
+			return fmt.Errorf("nil event channel")
+		}
+		c.events = ch
+
+		return nil
+	}
+}
+
+// emit sends an event about an attempt of req to the event channel, if configured, without blocking.
+func (c *Client) emit(t AttemptEventType, req *http.Request, requestID string, attempt uint32, resp *http.Response, err error) {
+	if c.events == nil {
+		return
+	}
+
+	e := AttemptEvent{
+		Type:      t,
+		Time:      time.Now(),
+		RequestID: requestID,
+		Method:    req.Method,
+		URL:       loggedURL(req.URL),
+		Attempt:   attempt,
+		Err:       err,
+	}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+	}
+
+	select {
+	case c.events <- e:
+	default:
+	}
+}
+
+// RequestSnapshot captures a request as built by the caller, so that it can be replayed later.
+// Headers added by the client (authentication, signatures, request ID) aren't included, except
+// for the request ID which is available on its own.
+type RequestSnapshot struct {
+	RequestID string
+	Method    string
+	URL       string
+	Header    http.Header
+	// Body is the request payload; it's shared with the caller and must not be modified.
+	Body []byte
+}
+
+// WithOnExhausted calls fn when a request failed and no more attempts will be made, with the
+// request and the error of each attempt, so failed requests can be routed to a dead-letter queue
+// or an alerting path in one place. fn runs synchronously before the request returns.
+func WithOnExhausted(fn func(req RequestSnapshot, errs []error)) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil exhausted hook")
+		}
+		c.onExhausted = fn
+
+		return nil
+	}
+}
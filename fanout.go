@@ -0,0 +1,117 @@
+package retryablehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FanOutPolicy decides when FanOut is done and whether it succeeded.
+type FanOutPolicy string
+
+const (
+	// FanOutCollectAll waits for every request; their failures are only reported in the result.
+	FanOutCollectAll FanOutPolicy = "collect-all"
+	// FanOutFailFast fails as soon as a request fails, cancelling the requests still in progress.
+	FanOutFailFast FanOutPolicy = "fail-fast"
+	// FanOutQuorum succeeds as soon as FanOutOptions.Quorum requests succeed, and fails as soon as
+	// too many requests failed for that to happen, cancelling the requests still in progress.
+	FanOutQuorum FanOutPolicy = "quorum"
+)
+
+// ErrQuorumNotReached is returned by FanOut when too many requests failed to reach the quorum.
+var ErrQuorumNotReached = errors.New("quorum not reached")
+
+// FanOutOptions configures FanOut.
+type FanOutOptions struct {
+	// Policy defaults to FanOutCollectAll.
+	Policy FanOutPolicy
+	// Quorum is the number of successful requests required by FanOutQuorum.
+	Quorum int
+	// RequestOptions apply to every request.
+	RequestOptions []RequestOption
+}
+
+// FanOutResult holds the outcome of every request of FanOut, in the order of the requests.
+type FanOutResult struct {
+	Responses []*http.Response
+	Errors    []error
+	// Succeeded and Failed count the requests that succeeded and failed, including those cancelled
+	// once the outcome was decided.
+	Succeeded int
+	Failed    int
+}
+
+// FanOut performs reqs concurrently like DoRequest, and returns their outcomes once the policy is
+// decided. Requests still in progress at that point are cancelled and waited for, so the result is
+// complete. The error reports whether the policy was met; the caller must close the bodies of the
+// responses in the result either way.
+func (c *Client) FanOut(ctx context.Context, reqs []*http.Request, opts FanOutOptions) (*FanOutResult, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no requests specified")
+	}
+
+	policy := opts.Policy
+	switch policy {
+	case "":
+		policy = FanOutCollectAll
+	case FanOutCollectAll, FanOutFailFast:
+	case FanOutQuorum:
+		if opts.Quorum < 1 || opts.Quorum > len(reqs) {
+			return nil, fmt.Errorf("invalid quorum value '%d'", opts.Quorum)
+		}
+	default:
+		return nil, fmt.Errorf("invalid fan out policy '%s'", policy)
+	}
+
+	// Start every request, and collect their indexes as they complete.
+	futures := make([]*Future, len(reqs))
+	completed := make(chan int, len(reqs))
+	for i, req := range reqs {
+		if req == nil {
+			return nil, fmt.Errorf("nil request at index %d", i)
+		}
+	}
+	for i, req := range reqs {
+		futures[i] = c.DoAsync(req.WithContext(ctx), opts.RequestOptions...)
+		go func() {
+			<-futures[i].Done()
+			completed <- i
+		}()
+	}
+
+	result := &FanOutResult{Responses: make([]*http.Response, len(reqs)), Errors: make([]error, len(reqs))}
+	var err error
+	decided := false
+	for range reqs {
+		i := <-completed
+		result.Responses[i], result.Errors[i] = futures[i].resp, futures[i].err
+		if result.Errors[i] == nil {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+		if decided {
+			continue
+		}
+
+		switch {
+		case policy == FanOutFailFast && result.Errors[i] != nil:
+			decided, err = true, fmt.Errorf("request %d failed: %w", i, result.Errors[i])
+		case policy == FanOutQuorum && result.Succeeded >= opts.Quorum:
+			decided = true
+		case policy == FanOutQuorum && result.Failed > len(reqs)-opts.Quorum:
+			decided, err = true, fmt.Errorf("%w: %d of %d requests failed", ErrQuorumNotReached, result.Failed, len(reqs))
+		}
+
+		// Cancel the requests left, which then complete right away. Those already complete keep their response.
+		if decided {
+			for _, f := range futures {
+				f.Cancel()
+			}
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,226 @@
+package retryablehttp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithRequestIDHeader assigns a unique ID to every logical request, sent in the given header on each
+// of its attempts so that client and server logs can be correlated across retries. A nil generator
+// selects random UUIDv4 IDs. Requests that already set the header keep their own ID. Errors returned
+// for a request carry its ID, which RequestIDFromError retrieves.
+func WithRequestIDHeader(header string, generator func() string) ClientOption {
+	return func(c *Client) error {
+		if header == "" {
+			return fmt.Errorf("empty request id header")
+		}
+		if generator == nil {
+			generator = newUUID
+		}
+		c.requestIDHeader = http.CanonicalHeaderKey(header)
+		c.requestIDGenerator = generator
+
+		return nil
+	}
+}
+
+// assignRequestID sets the request ID header on req, unless already present, and returns the ID.
+func (c *Client) assignRequestID(req *http.Request) string {
+	if c.requestIDHeader == "" {
+		return ""
+	}
+
+	id := req.Header.Get(c.requestIDHeader)
+	if id == "" {
+		id = c.requestIDGenerator()
+		req.Header.Set(c.requestIDHeader, id)
+	}
+
+	return id
+}
+
+// requestIDError attaches the ID of the failed request to an error.
+type requestIDError struct {
+	id  string
+	err error
+}
+
+func (e *requestIDError) Error() string {
+	return fmt.Sprintf("request id '%s': %s", e.id, e.err)
+}
+
+func (e *requestIDError) Unwrap() error {
+	return e.err
+}
+
+// withRequestID wraps err with the request ID, if any.
+func withRequestID(id string, err error) error {
+	if id == "" || err == nil {
+		return err
+	}
+
+	return &requestIDError{id: id, err: err}
+}
+
+// RequestIDFromError returns the ID of the request that produced err, if request IDs are enabled.
+func RequestIDFromError(err error) (string, bool) {
+	var idErr *requestIDError
+	if errors.As(err, &idErr) {
+		return idErr.id, true
+	}
+
+	return "", false
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// WithContextHeaders sets headers derived from the request context on every attempt, so values such
+// as a tenant or correlation ID stored in the context are propagated to the upstream. Headers passed
+// explicitly to the request take precedence.
+func WithContextHeaders(fn func(ctx context.Context) map[string]string) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil context headers function")
+		}
+		c.contextHeaders = fn
+
+		return nil
+	}
+}
+
+// applyContextHeaders sets the headers derived from the request context on req, skipping any
+// header present in explicit.
+func (c *Client) applyContextHeaders(req *http.Request, explicit http.Header) {
+	if c.contextHeaders == nil {
+		return
+	}
+
+	for k, v := range c.contextHeaders(req.Context()) {
+		if _, ok := explicit[http.CanonicalHeaderKey(k)]; ok {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+}
+
+// WithPerAttemptMutator calls fn on the request of every attempt, numbered from 0, right before it's
+// signed and sent, so time-sensitive values such as nonces, timestamps or trace IDs are regenerated for
+// every retry rather than replayed. An error from fn fails the attempt.
+func WithPerAttemptMutator(fn func(attempt uint32, req *http.Request) error) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil per-attempt mutator")
+		}
+		c.attemptMutator = fn
+
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with every request, replacing the default
+// "retryablehttp/<version> Go/<goversion>". When a request sets its own User-Agent, the client's
+// is appended to it rather than replacing it. An empty userAgent disables the client User-Agent.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) error {
+		c.userAgent = userAgent
+
+		return nil
+	}
+}
+
+// applyUserAgent sets the client User-Agent on req, appending it to the one set by the caller, if any.
+func (c *Client) applyUserAgent(req *http.Request) {
+	if c.userAgent == "" {
+		return
+	}
+
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua+" "+c.userAgent)
+		return
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+}
+
+// WithDeadlineHeader writes the time left before the request context deadline, in milliseconds, to
+// the given header (e.g. "X-Request-Timeout-Ms") on every attempt, so downstream services can shed
+// work the client is going to abandon anyway. Nothing is sent when the context has no deadline.
+func WithDeadlineHeader(header string) ClientOption {
+	return func(c *Client) error {
+		if header == "" {
+			return fmt.Errorf("empty deadline header")
+		}
+		c.deadlineHeader = header
+
+		return nil
+	}
+}
+
+// applyDeadlineHeader sets the remaining time before the request deadline on req.
+func (c *Client) applyDeadlineHeader(req *http.Request) {
+	if c.deadlineHeader == "" {
+		return
+	}
+
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		req.Header.Del(c.deadlineHeader)
+		return
+	}
+
+	remaining := max(time.Until(deadline).Milliseconds(), 0)
+	req.Header.Set(c.deadlineHeader, strconv.FormatInt(remaining, 10))
+}
+
+// WithHostHeader sends every request with the given Host header instead of the host of its URL, e.g. to
+// reach a virtual host through an IP address or a test proxy. Requests setting their own host keep it.
+// The TLS server name still follows the URL, see WithTLSServerName.
+func WithHostHeader(host string) ClientOption {
+	return func(c *Client) error {
+		if host == "" {
+			return fmt.Errorf("empty host header")
+		}
+		c.hostHeader = host
+
+		return nil
+	}
+}
+
+// WithRequestHostHeader sends a single request with the given Host header instead of the host of its
+// URL, overriding WithHostHeader.
+func WithRequestHostHeader(host string) RequestOption {
+	return func(ro *requestOptions) error {
+		if host == "" {
+			return fmt.Errorf("empty host header")
+		}
+		ro.host = host
+
+		return nil
+	}
+}
+
+// hostHeaderFor returns the Host header req is sent with on every attempt: the request option, the host
+// set on req itself when it differs from its URL, or else the client option. An empty host means the
+// host of the URL.
+func (c *Client) hostHeaderFor(req *http.Request, ro *requestOptions) string {
+	switch {
+	case ro.host != "":
+		return ro.host
+	case req.Host != "" && req.Host != req.URL.Host:
+		return req.Host
+	default:
+		return c.hostHeader
+	}
+}
@@ -0,0 +1,233 @@
+package retryablehttp
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// hostStatsSlots and hostStatsSlotDuration divide the rolling window of host statistics into slots
+	// that expire one at a time, so the window slides every slot duration.
+	hostStatsSlots        = 12
+	hostStatsSlotDuration = 5 * time.Second
+	hostStatsWindow       = hostStatsSlots * hostStatsSlotDuration
+	// hostStatsLatencyBins is the number of latency histogram bins, each 2^(1/4) times wider than the
+	// previous one, from 1µs up to more than an hour.
+	hostStatsLatencyBins = 128
+)
+
+// HostStats summarizes the attempts sent to a host over a rolling window of the last minute.
+type HostStats struct {
+	// Window is the span the statistics cover, shorter than a minute until the host has been used for
+	// that long.
+	Window time.Duration
+	// Attempts is the number of attempts sent, of which Failures were rejected or failed, and Retries
+	// weren't the first attempt of their request.
+	Attempts uint64
+	Failures uint64
+	Retries  uint64
+	// ErrorRate is the share of failed attempts and RetryRate the share of retries, from 0 to 1.
+	ErrorRate float64
+	RetryRate float64
+	// P50 and P95 are the median and 95th percentile attempt latencies, estimated within 20%.
+	P50 time.Duration
+	P95 time.Duration
+}
+
+// WithHostStats keeps statistics of the attempts sent to each host over a rolling window of the last
+// minute, see HostStats. They're also kept when WithAdaptiveAttempts or WithOutlierDetection, which
+// are driven by them, are enabled. Hosts left idle for a whole window are forgotten.
+func WithHostStats() ClientOption {
+	return func(c *Client) error {
+		c.hostStats = newHostStatsTracker()
+
+		return nil
+	}
+}
+
+// HostStats returns the statistics of the attempts sent to host over the last minute, e.g.
+// "api.example.com" or "api.example.com:8443" as found in the request URLs, or zero statistics if they
+// aren't kept, see WithHostStats.
+func (c *Client) HostStats(host string) HostStats {
+	if c.hostStats == nil {
+		return HostStats{}
+	}
+
+	return c.hostStats.snapshot(host, time.Time{}, time.Now())
+}
+
+// hostStatsSlot holds the attempts of one slot of a host window.
+type hostStatsSlot struct {
+	epoch     int64
+	attempts  uint64
+	failures  uint64
+	retries   uint64
+	latencies [hostStatsLatencyBins]uint32
+}
+
+// hostWindow is the rolling window of a host, whose slots are indexed by epoch modulo their number.
+type hostWindow struct {
+	started time.Time
+	slots   [hostStatsSlots]hostStatsSlot
+}
+
+// hostStatsTracker keeps a rolling window of attempt outcomes per host.
+type hostStatsTracker struct {
+	mu      sync.Mutex
+	hosts   map[string]*hostWindow
+	sweeper idleSweeper
+}
+
+// hostIdleTimeout is how long the per-host state of the client is kept for a host no longer used.
+const hostIdleTimeout = hostStatsWindow
+
+// idleSweeper spaces out the sweeps dropping the per-host state of idle hosts, so a client talking
+// to many hosts doesn't hold on to all of them.
+type idleSweeper struct {
+	// epoch is the slot of the host statistics during which the last sweep ran.
+	epoch int64
+}
+
+// due reports whether a sweep should run at now, at most once per slot of the host statistics.
+func (s *idleSweeper) due(now time.Time) bool {
+	epoch := slotEpoch(now)
+	if epoch == s.epoch {
+		return false
+	}
+	s.epoch = epoch
+
+	return true
+}
+
+func newHostStatsTracker() *hostStatsTracker {
+	return &hostStatsTracker{hosts: map[string]*hostWindow{}}
+}
+
+// slotEpoch returns the number of the slot now falls into.
+func slotEpoch(now time.Time) int64 {
+	return now.UnixNano() / int64(hostStatsSlotDuration)
+}
+
+// latencyBin returns the histogram bin of latency d.
+func latencyBin(d time.Duration) int {
+	us := d.Microseconds()
+	if us < 1 {
+		return 0
+	}
+
+	return min(int(4*math.Log2(float64(us))), hostStatsLatencyBins-1)
+}
+
+// latencyBinBound returns the upper bound of histogram bin i.
+func latencyBinBound(i int) time.Duration {
+	return time.Duration(math.Exp2(float64(i+1)/4) * float64(time.Microsecond))
+}
+
+// record accounts for an attempt to host that took latency.
+func (t *hostStatsTracker) record(host string, retry, success bool, latency time.Duration, now time.Time) {
+	host = strings.ToLower(host)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	epoch := slotEpoch(now)
+	if t.sweeper.due(now) {
+		t.sweep(epoch)
+	}
+
+	w, ok := t.hosts[host]
+	if !ok {
+		w = &hostWindow{started: now}
+		t.hosts[host] = w
+	}
+
+	s := &w.slots[epoch%hostStatsSlots]
+	if s.epoch != epoch {
+		*s = hostStatsSlot{epoch: epoch}
+	}
+	s.attempts++
+	if !success {
+		s.failures++
+	}
+	if retry {
+		s.retries++
+	}
+	s.latencies[latencyBin(latency)]++
+}
+
+// sweep drops the windows whose slots have all expired at epoch, so hosts no longer used don't hold
+// on to memory. t.mu must be held.
+func (t *hostStatsTracker) sweep(epoch int64) {
+	for host, w := range t.hosts {
+		idle := true
+		for i := range w.slots {
+			if w.slots[i].epoch > epoch-hostStatsSlots {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+// snapshot sums up the slots of the window of host that haven't expired at now, leaving out those that
+// started before since.
+func (t *hostStatsTracker) snapshot(host string, since, now time.Time) HostStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.hosts[strings.ToLower(host)]
+	if !ok {
+		return HostStats{}
+	}
+
+	started := w.started
+	epoch := slotEpoch(now)
+	oldest := epoch - hostStatsSlots + 1
+	if since.After(started) {
+		started = since
+		oldest = max(oldest, slotEpoch(since.Add(hostStatsSlotDuration-1)))
+	}
+
+	stats := HostStats{Window: max(0, min(now.Sub(started), hostStatsWindow))}
+	var latencies [hostStatsLatencyBins]uint64
+	for i := range w.slots {
+		s := &w.slots[i]
+		if s.epoch < oldest || s.epoch > epoch {
+			continue
+		}
+		stats.Attempts += s.attempts
+		stats.Failures += s.failures
+		stats.Retries += s.retries
+		for bin, n := range s.latencies {
+			latencies[bin] += uint64(n)
+		}
+	}
+	if stats.Attempts == 0 {
+		return stats
+	}
+
+	stats.ErrorRate = float64(stats.Failures) / float64(stats.Attempts)
+	stats.RetryRate = float64(stats.Retries) / float64(stats.Attempts)
+	stats.P50 = latencyPercentile(&latencies, stats.Attempts, 0.5)
+	stats.P95 = latencyPercentile(&latencies, stats.Attempts, 0.95)
+
+	return stats
+}
+
+// latencyPercentile returns the upper bound of the bin holding the q quantile of the n latencies.
+func latencyPercentile(latencies *[hostStatsLatencyBins]uint64, n uint64, q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(n)))
+	var seen uint64
+	for bin, count := range latencies {
+		if seen += count; seen >= rank {
+			return latencyBinBound(bin)
+		}
+	}
+
+	return latencyBinBound(hostStatsLatencyBins - 1)
+}
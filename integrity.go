@@ -0,0 +1,129 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrIntegrityMismatch reports a response body that doesn't match the digest announced in its headers,
+// e.g. because it was corrupted or truncated in transit.
+var ErrIntegrityMismatch = errors.New("response body integrity mismatch")
+
+// WithIntegrityCheck checks the body of the responses accepted by the policy against the digests
+// announced in their Content-MD5, Digest (RFC 3230), Content-Digest and Repr-Digest (RFC 9530)
+// headers, reading it into memory when any is present. A mismatch is retried as corruption in transit,
+// and is reported with ErrIntegrityMismatch once attempts run out. Unknown digest algorithms are ignored,
+// as are bodies decompressed by net/http, whose digests cover the compressed bytes.
+func WithIntegrityCheck(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.integrityCheck = enabled
+
+		return nil
+	}
+}
+
+// digestAlgorithms maps the lowercase algorithm names of digest headers to their hash function.
+var digestAlgorithms = map[string]func(b []byte) []byte{
+	"md5":     func(b []byte) []byte { sum := md5.Sum(b); return sum[:] },
+	"sha":     func(b []byte) []byte { sum := sha1.Sum(b); return sum[:] },
+	"sha-256": func(b []byte) []byte { sum := sha256.Sum256(b); return sum[:] },
+	"sha-512": func(b []byte) []byte { sum := sha512.Sum512(b); return sum[:] },
+}
+
+// expectedDigest is a digest announced by a response header.
+type expectedDigest struct {
+	header    string
+	algorithm string
+	sum       []byte
+}
+
+// checkIntegrity reads the body of resp into memory if it announces any digest, and checks it matches.
+func checkIntegrity(req *http.Request, resp *http.Response) error {
+	if req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified || resp.Uncompressed {
+		return nil
+	}
+
+	digests := responseDigests(resp)
+	if len(digests) == 0 {
+		return nil
+	}
+
+	body, err := bufferResponseBody(resp)
+	if err != nil {
+		return err
+	}
+
+	sums := map[string][]byte{}
+	for _, d := range digests {
+		sum, ok := sums[d.algorithm]
+		if !ok {
+			sum = digestAlgorithms[d.algorithm](body)
+			sums[d.algorithm] = sum
+		}
+		if !bytes.Equal(sum, d.sum) {
+			return fmt.Errorf("%w: %s digest of %s header doesn't match the body", ErrIntegrityMismatch, d.algorithm, d.header)
+		}
+	}
+
+	return nil
+}
+
+// responseDigests returns the digests announced by the headers of resp with a supported algorithm.
+// Digest and Repr-Digest cover the whole representation, so they're skipped for partial content.
+func responseDigests(resp *http.Response) []expectedDigest {
+	var digests []expectedDigest
+	add := func(header, algorithm, encoded string) {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if _, ok := digestAlgorithms[algorithm]; !ok {
+			return
+		}
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			// A malformed digest can't match any body.
+			sum = nil
+		}
+		digests = append(digests, expectedDigest{header: header, algorithm: algorithm, sum: sum})
+	}
+
+	if v := resp.Header.Get("Content-MD5"); v != "" {
+		add("Content-MD5", "md5", v)
+	}
+
+	partial := resp.StatusCode == http.StatusPartialContent
+	if !partial {
+		for _, v := range resp.Header.Values("Digest") {
+			for _, member := range strings.Split(v, ",") {
+				if algorithm, encoded, ok := strings.Cut(member, "="); ok {
+					add("Digest", algorithm, encoded)
+				}
+			}
+		}
+	}
+
+	for _, header := range []string{"Content-Digest", "Repr-Digest"} {
+		if partial && header == "Repr-Digest" {
+			continue
+		}
+		for _, v := range resp.Header.Values(header) {
+			for _, member := range strings.Split(v, ",") {
+				// Dictionary members of RFC 9530 hold the digest as a byte sequence, i.e. ":<base64>:".
+				algorithm, value, ok := strings.Cut(member, "=")
+				value = strings.TrimSpace(value)
+				if !ok || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+					continue
+				}
+				add(header, algorithm, value[1:len(value)-1])
+			}
+		}
+	}
+
+	return digests
+}
@@ -0,0 +1,20 @@
+// Package outcome classifies the outcomes of the deliveries of the outbox and webhook packages.
+package outcome // import "github.com/condrove10/retryablehttp/internal/outcome"
+
+import (
+	"errors"
+
+	"github.com/condrove10/retryablehttp"
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// Final reports whether a delivery failed in a way that another delivery wouldn't fix: the client
+// aborted its retries, it's misconfigured, or the request can't be built.
+func Final(err error) bool {
+	var (
+		configErr *retryablehttp.ConfigError
+		permanent *backoffpolicy.PermanentError
+	)
+
+	return errors.Is(err, retryablehttp.ErrRetriesAborted) || errors.As(err, &configErr) || errors.As(err, &permanent)
+}
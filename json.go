@@ -0,0 +1,50 @@
+package retryablehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// GetJSONStream sends a GET request with the retry logic of the client and hands fn a decoder
+// reading the live response body, so that very large JSON documents can be processed without
+// buffering them. The body is closed once fn returns. An Accept header for JSON is added unless
+// the caller sets one. Retries only cover obtaining the response: once fn starts decoding,
+// errors are returned as is.
+func (c *Client) GetJSONStream(url string, headers map[string]string, fn func(dec *json.Decoder) error, opts ...RequestOption) error {
+	if fn == nil {
+		return fmt.Errorf("nil json stream function")
+	}
+
+	headers = withDefaultHeader(headers, "Accept", "application/json")
+
+	resp, err := c.Get(url, headers, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := fn(json.NewDecoder(resp.Body)); err != nil {
+		return fmt.Errorf("failed to decode json stream: %w", err)
+	}
+
+	return nil
+}
+
+// withDefaultHeader returns a copy of headers with key set to value, unless already present.
+func withDefaultHeader(headers map[string]string, key, value string) map[string]string {
+	for k := range headers {
+		if http.CanonicalHeaderKey(k) == key {
+			return headers
+		}
+	}
+
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headers[key] = value
+
+	return headers
+}
@@ -0,0 +1,163 @@
+package retryablehttp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultJWTRefreshWindow is how long before expiry a JWT is refreshed when no window is configured.
+const defaultJWTRefreshWindow = 30 * time.Second
+
+// JWTAuthenticator authenticates requests with a JWT Bearer token obtained from a fetch function.
+// The token expiry is read from its "exp" claim: once the token enters the refresh window before
+// expiry, a new one is fetched in the background while the current one keeps being used, and
+// requests only wait for a refresh when the token has actually expired. Concurrent requests share
+// a single refresh in flight. A token rejected with 401 Unauthorized is discarded immediately, and the
+// request sent again with a new one.
+type JWTAuthenticator struct {
+	fetch         func(ctx context.Context) (string, error)
+	refreshWindow time.Duration
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *jwtRefresh
+}
+
+// jwtRefresh tracks a token fetch shared by every request waiting on it.
+type jwtRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewJWTAuthenticator returns a JWTAuthenticator fetching tokens with fetch and refreshing them
+// refreshWindow before they expire. A zero refreshWindow selects a default of 30 seconds.
+func NewJWTAuthenticator(fetch func(ctx context.Context) (string, error), refreshWindow time.Duration) (*JWTAuthenticator, error) {
+	if fetch == nil {
+		return nil, fmt.Errorf("nil jwt fetch function")
+	}
+	if refreshWindow < 0 {
+		return nil, fmt.Errorf("invalid jwt refresh window value '%s'", refreshWindow)
+	}
+	if refreshWindow == 0 {
+		refreshWindow = defaultJWTRefreshWindow
+	}
+
+	return &JWTAuthenticator{fetch: fetch, refreshWindow: refreshWindow}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// Token returns a valid token, fetching a new one if the cached token is missing or expired.
+func (a *JWTAuthenticator) Token(ctx context.Context) (string, error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	if a.token != "" && (a.expiry.IsZero() || now.Before(a.expiry)) {
+		token := a.token
+		// Refresh ahead of expiry without making this request wait for it.
+		if !a.expiry.IsZero() && now.Add(a.refreshWindow).After(a.expiry) {
+			a.startRefresh(ctx)
+		}
+		a.mu.Unlock()
+
+		return token, nil
+	}
+	call := a.startRefresh(ctx)
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// startRefresh returns the refresh in flight, starting one if needed. a.mu must be held.
+func (a *JWTAuthenticator) startRefresh(ctx context.Context) *jwtRefresh {
+	if a.inflight != nil {
+		return a.inflight
+	}
+
+	call := &jwtRefresh{done: make(chan struct{})}
+	a.inflight = call
+
+	// The refresh outlives the request that triggered it, as other requests may be waiting on it.
+	go func(ctx context.Context) {
+		token, err := a.fetch(ctx)
+		var expiry time.Time
+		if err == nil {
+			expiry, err = jwtExpiry(token)
+		}
+
+		a.mu.Lock()
+		if err == nil {
+			a.token, a.expiry = token, expiry
+		} else {
+			err = fmt.Errorf("failed to refresh jwt: %w", err)
+		}
+		a.inflight = nil
+		a.mu.Unlock()
+
+		call.token, call.err = token, err
+		close(call.done)
+	}(context.WithoutCancel(ctx))
+
+	return call
+}
+
+// OnUnauthorized discards the cached token if it's the one resp was requested with, so the next
+// authentication fetches a new one.
+func (a *JWTAuthenticator) OnUnauthorized(resp *http.Response) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && resp.Request.Header.Get("Authorization") == "Bearer "+a.token {
+		a.token, a.expiry = "", time.Time{}
+	}
+
+	return nil
+}
+
+// jwtExpiry returns the expiry encoded in the "exp" claim of token, or the zero time if it has none.
+// The signature isn't verified: the token is only inspected to schedule refreshes.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("malformed jwt: expected 3 segments, got %d", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed jwt payload: %w", err)
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("malformed jwt claims: %w", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(int64(*claims.Exp), 0), nil
+}
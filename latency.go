@@ -0,0 +1,74 @@
+package retryablehttp
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencySmoothing is the weight of the latest attempt in the per-host latency moving average.
+const latencySmoothing = 0.2
+
+// latencyTracker keeps an exponentially weighted moving average of the attempt latency per host, and
+// drops those of the hosts left idle.
+type latencyTracker struct {
+	mu        sync.Mutex
+	latencies map[string]*hostLatency
+	sweeper   idleSweeper
+}
+
+// hostLatency is the average attempt latency of a host, along with when it was last updated.
+type hostLatency struct {
+	average float64
+	updated time.Time
+}
+
+// record folds the latency of a single attempt into the host's average.
+func (l *latencyTracker) record(host string, latency time.Duration) {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sweeper.due(now) {
+		for key, h := range l.latencies {
+			if now.Sub(h.updated) > hostIdleTimeout {
+				delete(l.latencies, key)
+			}
+		}
+	}
+
+	h, ok := l.latencies[host]
+	if !ok {
+		l.latencies[host] = &hostLatency{average: float64(latency), updated: now}
+		return
+	}
+	h.average += latencySmoothing * (float64(latency) - h.average)
+	h.updated = now
+}
+
+// expected returns the average attempt latency to host, if any attempt was sent to it recently.
+func (l *latencyTracker) expected(host string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.latencies[strings.ToLower(host)]
+	if !ok {
+		return 0, false
+	}
+
+	return time.Duration(h.average), true
+}
+
+// WithLatencyAwareAttempts tracks the average attempt latency to each host and skips retries that
+// can't complete before the request context deadline: when the time left is shorter than the
+// expected latency, the errors of the previous attempts are returned right away instead of sending
+// an attempt whose response would be discarded.
+func WithLatencyAwareAttempts() ClientOption {
+	return func(c *Client) error {
+		c.latency = &latencyTracker{latencies: map[string]*hostLatency{}}
+
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// semaphore is a counting semaphore bounding the number of concurrent holders.
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// hostSemaphores lazily creates one semaphore per host, all sharing the same limit, and drops those
+// of the hosts left idle.
+type hostSemaphores struct {
+	mu      sync.Mutex
+	limit   uint32
+	sems    map[string]*hostSemaphore
+	sweeper idleSweeper
+}
+
+// hostSemaphore is the semaphore of a host, along with when it was last used.
+type hostSemaphore struct {
+	sem  semaphore
+	used time.Time
+}
+
+func newHostSemaphores(limit uint32) *hostSemaphores {
+	return &hostSemaphores{limit: limit, sems: map[string]*hostSemaphore{}}
+}
+
+func (h *hostSemaphores) get(host string) semaphore {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Semaphores still held are kept, so the limit of their host isn't lifted.
+	if h.sweeper.due(now) {
+		for key, s := range h.sems {
+			if len(s.sem) == 0 && now.Sub(s.used) > hostIdleTimeout {
+				delete(h.sems, key)
+			}
+		}
+	}
+
+	s, ok := h.sems[host]
+	if !ok {
+		s = &hostSemaphore{sem: make(semaphore, h.limit)}
+		h.sems[host] = s
+	}
+	s.used = now
+
+	return s.sem
+}
+
+// acquireSlots waits for a free slot in extra, if any, then in the global, per-host and adaptive
+// concurrency limits. The returned function releases every acquired slot and is safe to call more
+// than once.
+func (c *Client) acquireSlots(ctx context.Context, host string, extra semaphore) (func(), error) {
+	var held []func()
+
+	release := func() {
+		for _, release := range held {
+			release()
+		}
+		held = nil
+	}
+
+	if extra != nil {
+		if err := extra.acquire(ctx); err != nil {
+			return nil, err
+		}
+		held = append(held, extra.release)
+	}
+
+	if c.concurrency != nil {
+		if err := c.concurrency.acquire(ctx); err != nil {
+			return nil, err
+		}
+		held = append(held, c.concurrency.release)
+	}
+
+	if c.hostConcurrency != nil {
+		sem := c.hostConcurrency.get(host)
+		if err := sem.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, sem.release)
+	}
+
+	if c.aimdLimits != nil {
+		limiter := c.aimdLimits.get(host)
+		if err := limiter.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, limiter.release)
+	}
+
+	return release, nil
+}
+
+// releaseOnClose releases the attempt's concurrency slots once the response body is closed,
+// so a streamed response keeps counting against the limits until the caller is done with it.
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+
+	return err
+}
+
+// tokenBucket shapes traffic to a steady rate while allowing short bursts.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rps float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller must wait before it may be used.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// restore gives back a reserved token that ended up unused.
+func (b *tokenBucket) restore() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d := b.reserve(time.Now())
+	if d == 0 {
+		return nil
+	}
+
+	if err := sleepContext(ctx, d); err != nil {
+		b.restore()
+		return err
+	}
+
+	return nil
+}
+
+// WithHostRateLimit shapes the traffic sent to host, retries included, to rps requests per
+// second with bursts of up to burst requests. The host matches either exactly (including the
+// port) or by hostname.
+func WithHostRateLimit(host string, rps float64, burst int) ClientOption {
+	return func(c *Client) error {
+		if host == "" {
+			return fmt.Errorf("empty host")
+		}
+		if rps <= 0 {
+			return fmt.Errorf("invalid rate limit value '%g' for host '%s'", rps, host)
+		}
+		if burst < 1 {
+			return fmt.Errorf("invalid burst value '%d' for host '%s'", burst, host)
+		}
+		c.hostRateLimits[strings.ToLower(host)] = newTokenBucket(rps, burst)
+
+		return nil
+	}
+}
+
+// waitHostRateLimit blocks until the rate limit configured for the request host, if any, allows sending.
+func (c *Client) waitHostRateLimit(ctx context.Context, req *http.Request) error {
+	bucket, ok := c.hostRateLimits[strings.ToLower(req.URL.Host)]
+	if !ok {
+		bucket, ok = c.hostRateLimits[strings.ToLower(req.URL.Hostname())]
+	}
+	if !ok {
+		return nil
+	}
+
+	return bucket.wait(ctx)
+}
+
+// Limiter paces the attempts sent by the client, e.g. to share the rate limit of a vendor across a fleet
+// of instances. Wait blocks until an attempt keyed by key may be sent, or until ctx is done.
+type Limiter interface {
+	Wait(ctx context.Context, key string) error
+}
+
+// WithLimiter paces every attempt with limiter, on top of the limits of WithHostRateLimit. Attempts are
+// keyed by key, or by the lowercase host of their URL if key is nil. An attempt failing to wait for the
+// limiter, e.g. because its backend is unreachable, is retried like a failed attempt.
+func WithLimiter(limiter Limiter, key func(req *http.Request) string) ClientOption {
+	return func(c *Client) error {
+		if limiter == nil {
+			return fmt.Errorf("nil limiter")
+		}
+		if key == nil {
+			key = func(req *http.Request) string {
+				return strings.ToLower(req.URL.Host)
+			}
+		}
+		c.limiter = limiter
+		c.limiterKey = key
+
+		return nil
+	}
+}
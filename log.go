@@ -0,0 +1,234 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Logger receives the records logged by the client, leveled and with structured attributes as with
+// log/slog. An *slog.Logger is a Logger; the logadapter packages adapt other logging libraries.
+type Logger interface {
+	LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// WithLogger logs the outcome of every request to logger, at the info level for requests returning a
+// response and at the error level for failed ones. In debug mode, every attempt is logged too, see
+// SetDebug.
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			return fmt.Errorf("nil logger")
+		}
+		c.logger = logger
+
+		return nil
+	}
+}
+
+// WithLogSampling logs only 1 in n of the records of successful outcomes at level, so logging can stay
+// enabled on high-volume services: successful requests are logged at the info level and, in debug mode,
+// attempts at the debug level. Failed attempts and requests are always logged. Sampled records carry a
+// "sample_rate" attribute holding n, so counts can be scaled back. It can be set for each level.
+func WithLogSampling(level slog.Level, n uint32) ClientOption {
+	return func(c *Client) error {
+		if n == 0 {
+			return fmt.Errorf("invalid log sampling value '%d'", n)
+		}
+		if c.logSampling == nil {
+			c.logSampling = map[slog.Level]*logSampler{}
+		}
+		c.logSampling[level] = &logSampler{every: uint64(n)}
+
+		return nil
+	}
+}
+
+// logSampler keeps 1 in every records of a level.
+type logSampler struct {
+	every uint64
+	count atomic.Uint64
+}
+
+// sample reports whether the next record of a successful outcome at level is logged, along with the
+// sampling rate to report, or 0 if the level isn't sampled.
+func (c *Client) sample(level slog.Level) (bool, uint64) {
+	s, ok := c.logSampling[level]
+	if !ok || s.every == 1 {
+		return true, 0
+	}
+
+	return (s.count.Add(1)-1)%s.every == 0, s.every
+}
+
+// WithDebug starts the client in debug mode, see SetDebug.
+func WithDebug(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.debug.Store(enabled)
+
+		return nil
+	}
+}
+
+// SetDebug switches debug mode on or off while the client is in use, e.g. to investigate an incident
+// without restarting. In debug mode, every attempt is logged at the debug level with the headers of its
+// request and response, credentials and cookies masked; the logger must let debug records through.
+// Logged URLs always have their password and query values masked.
+// Debug mode has no effect without a logger, see WithLogger.
+func (c *Client) SetDebug(enabled bool) {
+	c.debug.Store(enabled)
+}
+
+// DebugEnabled reports whether the client is in debug mode.
+func (c *Client) DebugEnabled() bool {
+	return c.debug.Load()
+}
+
+// ToggleDebugOnSignal switches debug mode every time the process receives one of sigs, e.g.
+// syscall.SIGUSR1, until the client is closed.
+func (c *Client) ToggleDebugOnSignal(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		return fmt.Errorf("no debug toggle signal")
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ch:
+				enabled := !c.debug.Load()
+				c.debug.Store(enabled)
+				if c.logger != nil {
+					c.logger.LogAttrs(c.context, slog.LevelInfo, "http client debug mode switched", slog.Bool("enabled", enabled))
+				}
+			case <-c.lifecycle.done:
+				return
+			case <-c.context.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// maskedHeaders lists the headers whose values aren't logged.
+var maskedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// loggedHeader returns a copy of h with the values of sensitive headers masked.
+func loggedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range maskedHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			h[name] = []string{"[masked]"}
+		}
+	}
+
+	return h
+}
+
+// loggedURL returns u with its password and query values masked.
+func loggedURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+
+	masked := *u
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		if name, _, ok := strings.Cut(param, "="); ok {
+			params[i] = name + "=xxxxx"
+		}
+	}
+	masked.RawQuery = strings.Join(params, "&")
+
+	return masked.Redacted()
+}
+
+// logAttempt logs an attempt in debug mode.
+func (c *Client) logAttempt(ctx context.Context, req *http.Request, requestID string, attempt uint32, resp *http.Response, timing AttemptTiming) {
+	if c.logger == nil || !c.debug.Load() {
+		return
+	}
+	var rate uint64
+	if timing.Err == nil {
+		var keep bool
+		if keep, rate = c.sample(slog.LevelDebug); !keep {
+			return
+		}
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", loggedURL(req.URL)),
+		slog.Uint64("attempt", uint64(attempt)),
+		slog.Duration("duration", timing.End.Sub(timing.Start)),
+		slog.Bool("conn_reused", timing.ConnReused),
+		slog.Any("request_headers", loggedHeader(req.Header)),
+	}
+	if requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.Any("response_headers", loggedHeader(resp.Header)))
+	}
+	if timing.Err != nil {
+		attrs = append(attrs, slog.String("error", timing.Err.Error()))
+	}
+	if rate > 0 {
+		attrs = append(attrs, slog.Uint64("sample_rate", rate))
+	}
+
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "http attempt", attrs...)
+}
+
+// logRequest logs the outcome of a request.
+func (c *Client) logRequest(ctx context.Context, req *http.Request, requestID string, info *RetryInfo, resp *http.Response, err error) {
+	if c.logger == nil {
+		return
+	}
+	var rate uint64
+	if err == nil {
+		var keep bool
+		if keep, rate = c.sample(slog.LevelInfo); !keep {
+			return
+		}
+	}
+
+	var elapsed time.Duration
+	if n := len(info.Timings); n > 0 {
+		elapsed = info.Timings[n-1].End.Sub(info.Timings[0].Start)
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", loggedURL(req.URL)),
+		slog.Uint64("attempts", uint64(info.Attempts)),
+		slog.Duration("duration", elapsed),
+	}
+	if requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		c.logger.LogAttrs(ctx, slog.LevelError, "http request failed", attrs...)
+		return
+	}
+	if rate > 0 {
+		attrs = append(attrs, slog.Uint64("sample_rate", rate))
+	}
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "http request succeeded", attrs...)
+}
@@ -0,0 +1,19 @@
+// Package logattr converts slog attributes for the logadapter packages.
+package logattr // import "github.com/condrove10/retryablehttp/logadapter/internal/logattr"
+
+import "log/slog"
+
+// Value returns the value of an attribute, with groups as maps.
+func Value(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+
+	group := make(map[string]any, len(v.Group()))
+	for _, attr := range v.Group() {
+		group[attr.Key] = Value(attr.Value)
+	}
+
+	return group
+}
@@ -0,0 +1,44 @@
+// Package logrusadapter adapts a logrus logger to the retryablehttp.Logger interface.
+package logrusadapter // import "github.com/condrove10/retryablehttp/logadapter/logrusadapter"
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/condrove10/retryablehttp/logadapter/internal/logattr"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger logs the records of retryablehttp to a logrus logger.
+type Logger struct {
+	l logrus.FieldLogger
+}
+
+// New returns a Logger writing to l, a *logrus.Logger or a *logrus.Entry carrying fields of its own.
+func New(l logrus.FieldLogger) *Logger {
+	return &Logger{l: l}
+}
+
+func (a *Logger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	fields := make(logrus.Fields, len(attrs))
+	for _, attr := range attrs {
+		fields[attr.Key] = logattr.Value(attr.Value)
+	}
+
+	entry := a.l.WithFields(fields).WithContext(ctx)
+	entry.Log(logrusLevel(level), msg)
+}
+
+// logrusLevel maps an slog level to the logrus level at or below it.
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return logrus.DebugLevel
+	case level < slog.LevelWarn:
+		return logrus.InfoLevel
+	case level < slog.LevelError:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
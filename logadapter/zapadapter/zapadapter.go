@@ -0,0 +1,48 @@
+// Package zapadapter adapts a zap logger to the retryablehttp.Logger interface.
+package zapadapter // import "github.com/condrove10/retryablehttp/logadapter/zapadapter"
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/condrove10/retryablehttp/logadapter/internal/logattr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger logs the records of retryablehttp to a zap logger.
+type Logger struct {
+	l *zap.Logger
+}
+
+// New returns a Logger writing to l. Records report the client code logging them as their caller.
+func New(l *zap.Logger) *Logger {
+	return &Logger{l: l.WithOptions(zap.AddCallerSkip(1))}
+}
+
+func (a *Logger) LogAttrs(_ context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	lvl := zapLevel(level)
+	if !a.l.Core().Enabled(lvl) {
+		return
+	}
+
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = append(fields, zap.Any(attr.Key, logattr.Value(attr.Value)))
+	}
+	a.l.Log(lvl, msg, fields...)
+}
+
+// zapLevel maps an slog level to the zap level at or below it.
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
@@ -0,0 +1,47 @@
+// Package zerologadapter adapts a zerolog logger to the retryablehttp.Logger interface.
+package zerologadapter // import "github.com/condrove10/retryablehttp/logadapter/zerologadapter"
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/condrove10/retryablehttp/logadapter/internal/logattr"
+	"github.com/rs/zerolog"
+)
+
+// Logger logs the records of retryablehttp to a zerolog logger.
+type Logger struct {
+	l zerolog.Logger
+}
+
+// New returns a Logger writing to l.
+func New(l zerolog.Logger) *Logger {
+	return &Logger{l: l}
+}
+
+func (a *Logger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	event := a.l.WithLevel(zerologLevel(level))
+	if event == nil {
+		return
+	}
+
+	fields := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		fields[attr.Key] = logattr.Value(attr.Value)
+	}
+	event.Ctx(ctx).Fields(fields).Msg(msg)
+}
+
+// zerologLevel maps an slog level to the zerolog level at or below it.
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
@@ -0,0 +1,108 @@
+package retryablehttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// NDJSONResumeFunc returns the URL to reconnect to after the stream was interrupted, given the
+// number of lines received so far and the last one, e.g. by adding an offset or cursor parameter.
+// Returning an empty URL gives up on resuming.
+type NDJSONResumeFunc[T any] func(received uint64, last T) string
+
+// StreamNDJSON sends a GET request with the retry logic of c and returns an iterator over the values
+// decoded from each line of the NDJSON (JSON Lines) response. When the connection drops mid-stream
+// and resume is set, the stream is reopened at the URL it returns, after waiting according to the
+// client backoff strategy; the stream is resumed at most as many times as the client attempts.
+// Without resume, an interruption ends the iteration with an error. Iteration also stops at the
+// first line that fails to decode.
+func StreamNDJSON[T any](c *Client, url string, headers map[string]string, resume NDJSONResumeFunc[T], opts ...RequestOption) iter.Seq2[T, error] {
+	headers = withDefaultHeader(headers, "Accept", "application/x-ndjson")
+
+	return func(yield func(T, error) bool) {
+		var (
+			received uint64
+			last     T
+			stopped  bool
+			target   = url
+		)
+
+		ro, err := newRequestOptions(opts)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		settings := c.defaultSettings()
+		curve, err := settings.backoffCurve()
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+
+		// Bound the reconnections and the waits between them as execute bounds attempts.
+		ctx, cancel := c.requestContext(ro.ctx)
+		defer cancel()
+
+		err = backoffpolicy.BackoffPolicyCurve(ctx, curve, settings.attempts, func(a backoffpolicy.Attempt) error {
+			if a.Number > 0 {
+				if target = resume(received, last); target == "" {
+					return backoffpolicy.Permanent(fmt.Errorf("ndjson stream cannot be resumed"))
+				}
+			}
+
+			// Opening the stream is already retried by the client.
+			resp, err := c.Get(target, headers, opts...)
+			if err != nil {
+				return backoffpolicy.Permanent(err)
+			}
+			defer resp.Body.Close()
+
+			reader := bufio.NewReader(resp.Body)
+			for {
+				line, readErr := reader.ReadBytes('\n')
+
+				// A partial line is only complete at the end of the stream.
+				if readErr == nil || readErr == io.EOF {
+					if line = bytes.TrimSpace(line); len(line) > 0 {
+						var v T
+						if err := json.Unmarshal(line, &v); err != nil {
+							return backoffpolicy.Permanent(fmt.Errorf("failed to decode ndjson line %d: %w", received+1, err))
+						}
+						received++
+						last = v
+
+						if !yield(v, nil) {
+							stopped = true
+							return nil
+						}
+					}
+				}
+
+				switch {
+				case readErr == nil:
+					continue
+				case errors.Is(readErr, io.EOF):
+					return nil
+				case resume == nil:
+					return backoffpolicy.Permanent(fmt.Errorf("ndjson stream interrupted: %w", readErr))
+				default:
+					return fmt.Errorf("ndjson stream interrupted: %w", readErr)
+				}
+			}
+		})
+
+		if err != nil && !stopped {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package retryablehttp
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrUnexpectedContentType is wrapped by the error of attempts whose response doesn't have one of the
+// content types expected with WithExpectContentType.
+var ErrUnexpectedContentType = errors.New("unexpected response content type")
+
+// WithAccept sets the Accept header of a request to the given media types, e.g. "application/json",
+// overriding the header set by the caller or by typed helpers such as GetJSONStream.
+func WithAccept(mediaTypes ...string) RequestOption {
+	return func(ro *requestOptions) error {
+		if len(mediaTypes) == 0 {
+			return fmt.Errorf("no media types specified")
+		}
+		ro.accept = strings.Join(mediaTypes, ", ")
+
+		return nil
+	}
+}
+
+// WithExpectContentType rejects responses accepted by the policy whose Content-Type doesn't match one
+// of the given media types, e.g. an HTML error page served with a 200 status by a proxy. Media types
+// may use a wildcard subtype, e.g. "text/*". Rejected responses are retried like any other rejection,
+// with an error wrapping ErrUnexpectedContentType.
+func WithExpectContentType(mediaTypes ...string) RequestOption {
+	return func(ro *requestOptions) error {
+		if len(mediaTypes) == 0 {
+			return fmt.Errorf("no media types specified")
+		}
+		for _, mediaType := range mediaTypes {
+			parsed, _, err := mime.ParseMediaType(mediaType)
+			if err != nil {
+				return fmt.Errorf("invalid media type '%s': %w", mediaType, err)
+			}
+			ro.contentTypes = append(ro.contentTypes, parsed)
+		}
+
+		return nil
+	}
+}
+
+// checkContentType returns an error if the media type of resp isn't one of expected.
+func checkContentType(resp *http.Response, expected []string) error {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, e := range expected {
+			if e == mediaType || e == "*/*" {
+				return nil
+			}
+			if prefix, ok := strings.CutSuffix(e, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w '%s' with HTTP response status code (%d)", ErrUnexpectedContentType, contentType, resp.StatusCode)
+}
@@ -0,0 +1,236 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM message flags.
+const (
+	ntlmNegotiateUnicode         = 0x00000001
+	ntlmNegotiateOEM             = 0x00000002
+	ntlmRequestTarget            = 0x00000004
+	ntlmNegotiateNTLM            = 0x00000200
+	ntlmNegotiateAlwaysSign      = 0x00008000
+	ntlmNegotiateExtendedSession = 0x00080000
+	ntlmNegotiateTargetInfo      = 0x00800000
+	ntlmNegotiate128             = 0x20000000
+	ntlmNegotiate56              = 0x80000000
+)
+
+// ntlmNegotiateFlags are the flags requested by the negotiate message.
+const ntlmNegotiateFlags uint32 = ntlmNegotiateUnicode | ntlmNegotiateOEM | ntlmRequestTarget | ntlmNegotiateNTLM |
+	ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSession | ntlmNegotiate128 | ntlmNegotiate56
+
+// ntlmSignature starts every NTLM message.
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAuth authenticates requests with NTLMv2: every attempt opens the handshake with a negotiate
+// message, and the challenge of the server is answered with an authenticate message.
+type ntlmAuth struct {
+	domain   string
+	username string
+	password string
+}
+
+// WithNTLMAuth authenticates requests with NTLMv2, unless the request carries its own credentials, for
+// IIS servers and APIs behind corporate gateways. The handshake takes two round trips within each attempt,
+// on the same keep-alive connection, so it doesn't work with transports that disable keep-alives. A
+// username given as "DOMAIN\user" sets the domain when domain is empty.
+func WithNTLMAuth(domain, username, password string) ClientOption {
+	return func(c *Client) error {
+		if username == "" {
+			return fmt.Errorf("empty ntlm username")
+		}
+		if d, u, ok := strings.Cut(username, `\`); ok && domain == "" {
+			domain, username = d, u
+		}
+		c.auth = &ntlmAuth{domain: domain, username: username, password: password}
+
+		return nil
+	}
+}
+
+func (a *ntlmAuth) Authenticate(req *http.Request) error {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateFlags)
+	req.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(msg))
+
+	return nil
+}
+
+// respondToChallenge answers the NTLM challenge of resp, if req opened the handshake.
+func (a *ntlmAuth) respondToChallenge(req *http.Request, resp *http.Response) (bool, error) {
+	negotiate, ok := strings.CutPrefix(req.Header.Get("Authorization"), "NTLM ")
+	if !ok {
+		return false, nil
+	}
+	if msg, err := base64.StdEncoding.DecodeString(negotiate); err != nil || len(msg) < 12 || binary.LittleEndian.Uint32(msg[8:]) != 1 {
+		return false, nil
+	}
+
+	challenge := authChallengeToken(resp, "NTLM")
+	if challenge == nil {
+		return false, nil
+	}
+
+	msg, err := a.authenticateMessage(challenge)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(msg))
+
+	return true, nil
+}
+
+// authenticateMessage returns the NTLMv2 authenticate message answering a challenge message.
+func (a *ntlmAuth) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 32 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, fmt.Errorf("invalid ntlm challenge message")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+
+	var targetInfo []byte
+	if flags&ntlmNegotiateTargetInfo != 0 && len(challenge) >= 48 {
+		length := int(binary.LittleEndian.Uint16(challenge[40:]))
+		offset := int(binary.LittleEndian.Uint32(challenge[44:]))
+		if offset+length > len(challenge) {
+			return nil, fmt.Errorf("invalid ntlm challenge message: target info out of bounds")
+		}
+		targetInfo = challenge[offset : offset+length]
+	}
+
+	// NTOWFv2 = HMAC-MD5(MD4(UTF-16LE(password)), UTF-16LE(UPPER(username) + domain)).
+	ntHash := md4.New()
+	ntHash.Write(utf16LE(a.password))
+	mac := hmac.New(md5.New, ntHash.Sum(nil))
+	mac.Write(utf16LE(strings.ToUpper(a.username) + a.domain))
+	ntowf := mac.Sum(nil)
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, fmt.Errorf("failed to generate ntlm client challenge: %w", err)
+	}
+
+	// The blob carries the time in Windows file time: 100ns intervals since 1601.
+	blob := make([]byte, 28, 28+len(targetInfo)+4)
+	blob[0], blob[1] = 1, 1
+	binary.LittleEndian.PutUint64(blob[8:], uint64(time.Now().UnixNano()/100+116444736000000000))
+	copy(blob[16:], clientChallenge)
+	blob = append(append(blob, targetInfo...), 0, 0, 0, 0)
+
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(serverChallenge)
+	mac.Write(blob)
+	ntResponse := append(mac.Sum(nil), blob...)
+
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(serverChallenge)
+	mac.Write(clientChallenge)
+	lmResponse := append(mac.Sum(nil), clientChallenge...)
+
+	payloads := [][]byte{lmResponse, ntResponse, utf16LE(a.domain), utf16LE(a.username), nil, nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	for i, payload := range payloads {
+		field := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(len(msg)))
+		msg = append(msg, payload...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&ntlmNegotiateFlags|ntlmNegotiateUnicode)
+
+	return msg, nil
+}
+
+// utf16LE encodes s in UTF-16 little endian.
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+
+	return b
+}
+
+// authChallengeToken returns the base64 token of the scheme's challenge in the WWW-Authenticate headers
+// of resp, or nil if there's none.
+func authChallengeToken(resp *http.Response, scheme string) []byte {
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		name, token, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(name, scheme) || token == "" {
+			continue
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token)); err == nil {
+			return decoded
+		}
+	}
+
+	return nil
+}
+
+// negotiateAuth authenticates requests with the Negotiate scheme (RFC 4559), delegating the tokens to
+// a security library.
+type negotiateAuth struct {
+	token func(ctx context.Context, host string, challenge []byte) ([]byte, error)
+}
+
+// WithNegotiateAuth authenticates requests with the Negotiate scheme (SPNEGO, RFC 4559), unless the
+// request carries its own credentials. token produces the tokens of the handshake with host, usually
+// through a Kerberos library or the Windows SSPI: it's called with a nil challenge to open the handshake
+// on every attempt, and with the token of the server when a 401 response continues it, in which case
+// the attempt is answered and sent again right away.
+func WithNegotiateAuth(token func(ctx context.Context, host string, challenge []byte) ([]byte, error)) ClientOption {
+	return func(c *Client) error {
+		if token == nil {
+			return fmt.Errorf("nil negotiate token function")
+		}
+		c.auth = &negotiateAuth{token: token}
+
+		return nil
+	}
+}
+
+func (a *negotiateAuth) Authenticate(req *http.Request) error {
+	token, err := a.token(req.Context(), req.URL.Hostname(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to produce negotiate token: %w", err)
+	}
+	req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+
+	return nil
+}
+
+// respondToChallenge answers the Negotiate continuation token of resp, if any.
+func (a *negotiateAuth) respondToChallenge(req *http.Request, resp *http.Response) (bool, error) {
+	challenge := authChallengeToken(resp, "Negotiate")
+	if challenge == nil {
+		return false, nil
+	}
+
+	token, err := a.token(req.Context(), req.URL.Hostname(), challenge)
+	if err != nil {
+		return false, fmt.Errorf("failed to produce negotiate token: %w", err)
+	}
+	req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+
+	return true, nil
+}
@@ -0,0 +1,216 @@
+// Package outbox relays requests stored in a transactional outbox, e.g. a database table written in
+// the same transaction as the business data, using a retryablehttp client to execute them.
+package outbox // import "github.com/condrove10/retryablehttp/outbox"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/condrove10/retryablehttp"
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+	"github.com/condrove10/retryablehttp/internal/outcome"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultConcurrency  = 4
+)
+
+// defaultSchedule delays the next delivery of a failed message by a minute, doubling every time.
+var defaultSchedule backoffpolicy.Schedule = backoffpolicy.Curve{Initial: time.Minute, Multiplier: 2}
+
+// Message is a request stored in the outbox.
+type Message struct {
+	ID     string
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	// Attempts is the number of deliveries already attempted, as recorded by the store.
+	Attempts uint32
+}
+
+// Store gives access to the outbox. Implementations are typically backed by a database table.
+type Store interface {
+	// Fetch returns up to limit messages due for delivery.
+	Fetch(ctx context.Context, limit int) ([]Message, error)
+	// MarkInFlight claims a fetched message, reporting false if it was claimed by another relay in the
+	// meantime, e.g. with an UPDATE conditioned on the message still being pending.
+	MarkInFlight(ctx context.Context, id string) (bool, error)
+	// Ack records that a message was delivered.
+	Ack(ctx context.Context, id string) error
+	// Nack records that the delivery of a message failed with cause, and that it's due again at retryAt.
+	// A zero retryAt means the message was given up on.
+	Nack(ctx context.Context, id string, cause error, retryAt time.Time) error
+}
+
+// Config configures a Relay. Zero-valued fields select the defaults.
+type Config struct {
+	// Client executes the messages, retrying each delivery according to its own configuration.
+	Client *retryablehttp.Client
+	Store  Store
+	// BatchSize is the maximum number of messages fetched at once. It defaults to 100.
+	BatchSize int
+	// PollInterval is the wait between fetches when the outbox is drained. It defaults to 1 second.
+	PollInterval time.Duration
+	// Concurrency is the number of messages delivered concurrently. It defaults to 4.
+	Concurrency int
+	// Schedule gives the delays between the deliveries of a message, either a backoffpolicy.Curve or
+	// backoffpolicy.Intervals. It defaults to 1 minute, doubling every delivery.
+	Schedule backoffpolicy.Schedule
+	// MaxAttempts is the number of deliveries before a message is given up on. It defaults to one
+	// delivery per interval plus the first one for backoffpolicy.Intervals, and to no limit otherwise.
+	MaxAttempts uint32
+}
+
+// Relay moves messages from the outbox to their destination.
+type Relay struct {
+	cfg Config
+}
+
+// NewRelay returns a Relay for cfg.
+func NewRelay(cfg Config) (*Relay, error) {
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+	if cfg.Store == nil {
+		return nil, fmt.Errorf("nil store")
+	}
+	if cfg.BatchSize < 0 {
+		return nil, fmt.Errorf("invalid batch size value '%d'", cfg.BatchSize)
+	}
+	if cfg.PollInterval < 0 {
+		return nil, fmt.Errorf("invalid poll interval value '%s'", cfg.PollInterval)
+	}
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("invalid concurrency value '%d'", cfg.Concurrency)
+	}
+	if cfg.Schedule == nil {
+		cfg.Schedule = defaultSchedule
+	}
+	if err := cfg.Schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if intervals, ok := cfg.Schedule.(backoffpolicy.Intervals); ok && cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = uint32(len(intervals)) + 1
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+
+	return &Relay{cfg: cfg}, nil
+}
+
+// Run relays messages until ctx is done, fetching again right away while batches come back full.
+// Store errors are returned, ending the relay.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if n == r.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-time.After(r.cfg.PollInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// RunOnce relays a single batch of messages and returns the number of messages fetched.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	messages, err := r.cfg.Store.Fetch(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch outbox messages: %w", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		errs  []error
+		slots = make(chan struct{}, r.cfg.Concurrency)
+	)
+	for _, m := range messages {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := r.relay(ctx, m); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(messages), errors.Join(errs...)
+}
+
+// relay claims, delivers and settles a single message. Only store errors are returned.
+func (r *Relay) relay(ctx context.Context, m Message) error {
+	claimed, err := r.cfg.Store.MarkInFlight(ctx, m.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message '%s' in flight: %w", m.ID, err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	cause := r.deliver(ctx, m)
+	if cause == nil {
+		if err := r.cfg.Store.Ack(ctx, m.ID); err != nil {
+			return fmt.Errorf("failed to ack outbox message '%s': %w", m.ID, err)
+		}
+		return nil
+	}
+
+	// Give up on messages that can't succeed, or past the attempt limit; reschedule the others.
+	attempts := m.Attempts + 1
+	var retryAt time.Time
+	if !outcome.Final(cause) && (r.cfg.MaxAttempts == 0 || attempts < r.cfg.MaxAttempts) {
+		retryAt = time.Now().Add(r.cfg.Schedule.Delay(attempts))
+	}
+	if err := r.cfg.Store.Nack(context.WithoutCancel(ctx), m.ID, cause, retryAt); err != nil {
+		return fmt.Errorf("failed to nack outbox message '%s': %w", m.ID, err)
+	}
+
+	return nil
+}
+
+// deliver executes a message with the retry logic of the client.
+func (r *Relay) deliver(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, m.Method, m.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		return backoffpolicy.Permanent(fmt.Errorf("failed to create outbox request: %w", err))
+	}
+	for k, v := range m.Header {
+		req.Header[k] = v
+	}
+
+	resp, err := r.cfg.Client.DoRequest(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
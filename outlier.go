@@ -0,0 +1,151 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// outlierMinLatencySamples is the number of attempts an endpoint needs before its latency is compared.
+const outlierMinLatencySamples = 10
+
+// OutlierDetection configures the passive ejection of misbehaving endpoints, based on the outcome
+// of the attempts sent to them. Ejected endpoints are left out of rotation for a cooldown period.
+type OutlierDetection struct {
+	// ConsecutiveFailures ejects an endpoint after this many failed attempts in a row. Zero selects a default of 5.
+	ConsecutiveFailures uint32
+	// LatencyFactor ejects an endpoint whose median attempt latency over the last minute, see HostStats,
+	// since it was last re-admitted exceeds this multiple of the median of those of all endpoints, e.g. 3. Zero disables latency-based
+	// ejection.
+	LatencyFactor float64
+	// BaseEjectionTime is the cooldown of a first ejection; an endpoint ejected again shortly after being
+	// re-admitted stays out proportionally longer. Zero selects a default of 30 seconds.
+	BaseEjectionTime time.Duration
+	// MaxEjectionPercent caps the share of endpoints ejected at the same time. Zero selects a default of 50.
+	MaxEjectionPercent uint32
+}
+
+// outlierState tracks the attempts sent to an endpoint. It's guarded by the pool mutex.
+type outlierState struct {
+	consecutiveFailures uint32
+	ejections           uint32
+	readmittedAt        time.Time
+}
+
+// WithOutlierDetection ejects endpoints configured with WithEndpoints from rotation when their attempts
+// keep failing or turn out much slower than the other endpoints, and re-admits them after a cooldown.
+// The cooldown grows with every ejection and shrinks back while the endpoint behaves, so a flapping
+// endpoint is re-admitted gradually. If every endpoint is unavailable, requests are still attempted
+// against all of them.
+func WithOutlierDetection(cfg OutlierDetection) ClientOption {
+	return func(c *Client) error {
+		if cfg.LatencyFactor < 0 || (cfg.LatencyFactor > 0 && cfg.LatencyFactor <= 1) {
+			return fmt.Errorf("invalid outlier latency factor value '%g'", cfg.LatencyFactor)
+		}
+		if cfg.BaseEjectionTime < 0 {
+			return fmt.Errorf("invalid outlier base ejection time value '%s'", cfg.BaseEjectionTime)
+		}
+		if cfg.MaxEjectionPercent > 100 {
+			return fmt.Errorf("invalid outlier max ejection percent value '%d'", cfg.MaxEjectionPercent)
+		}
+
+		if cfg.ConsecutiveFailures == 0 {
+			cfg.ConsecutiveFailures = 5
+		}
+		if cfg.BaseEjectionTime == 0 {
+			cfg.BaseEjectionTime = 30 * time.Second
+		}
+		if cfg.MaxEjectionPercent == 0 {
+			cfg.MaxEjectionPercent = 50
+		}
+		c.outlierDetection = &cfg
+
+		return nil
+	}
+}
+
+// ejected reports whether e is out of rotation at now.
+func (e *endpoint) ejected(now time.Time) bool {
+	return now.UnixNano() < e.ejectedUntil.Load()
+}
+
+// record accounts for the outcome of an attempt sent to e, ejecting it if it turned into an outlier.
+func (p *endpointPool) record(e *endpoint, success bool) {
+	cfg := p.outlierDetection
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Attempts that were already in flight when e was ejected don't count against it again.
+	if e.ejected(now) {
+		return
+	}
+
+	s := &e.outlier
+	if success {
+		s.consecutiveFailures = 0
+	} else {
+		s.consecutiveFailures++
+	}
+
+	if s.consecutiveFailures >= cfg.ConsecutiveFailures || p.slowOutlier(e, now) {
+		p.eject(e, now)
+	}
+}
+
+// slowOutlier reports whether the median latency of e exceeds the configured multiple of the median
+// of those of all endpoints, each counting the attempts since its last re-admission. p.mu must be held.
+func (p *endpointPool) slowOutlier(e *endpoint, now time.Time) bool {
+	factor := p.outlierDetection.LatencyFactor
+	if factor == 0 {
+		return false
+	}
+	stats := p.hostStats.snapshot(e.host, e.outlier.readmittedAt, now)
+	if stats.Attempts < outlierMinLatencySamples {
+		return false
+	}
+
+	latencies := make([]time.Duration, 0, len(p.endpoints))
+	for _, other := range p.endpoints {
+		if other := p.hostStats.snapshot(other.host, other.outlier.readmittedAt, now); other.Attempts >= outlierMinLatencySamples {
+			latencies = append(latencies, other.P50)
+		}
+	}
+	if len(latencies) < 2 {
+		return false
+	}
+	slices.Sort(latencies)
+
+	return float64(stats.P50) > factor*float64(latencies[len(latencies)/2])
+}
+
+// eject takes e out of rotation, unless too many endpoints are already ejected. p.mu must be held.
+func (p *endpointPool) eject(e *endpoint, now time.Time) {
+	cfg := p.outlierDetection
+
+	ejected := 0
+	for _, other := range p.endpoints {
+		if other.ejected(now) {
+			ejected++
+		}
+	}
+	if uint32(ejected+1)*100 > cfg.MaxEjectionPercent*uint32(len(p.endpoints)) {
+		return
+	}
+
+	// Every base ejection time spent back in rotation forgives one earlier ejection.
+	s := &e.outlier
+	if !s.readmittedAt.IsZero() {
+		forgiven := uint32(now.Sub(s.readmittedAt) / cfg.BaseEjectionTime)
+		s.ejections -= min(forgiven, s.ejections)
+	}
+	s.ejections++
+
+	cooldown := cfg.BaseEjectionTime * time.Duration(s.ejections)
+	e.ejectedUntil.Store(now.Add(cooldown).UnixNano())
+
+	// Give the endpoint a clean slate once it's re-admitted: its latency is only compared from then on.
+	s.consecutiveFailures = 0
+	s.readmittedAt = now.Add(cooldown)
+}
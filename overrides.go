@@ -0,0 +1,162 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// retrySettings holds the retry configuration resolved for a single logical request.
+type retrySettings struct {
+	attempts uint32
+	delay    time.Duration
+	strategy backoffpolicy.Strategy
+	curve    *backoffpolicy.Curve
+	policy   Policy
+}
+
+// backoffCurve returns the curve of delays between attempts: the custom curve if one applies,
+// otherwise the one of the strategy.
+func (s retrySettings) backoffCurve() (backoffpolicy.Curve, error) {
+	if s.curve != nil {
+		return *s.curve, nil
+	}
+
+	return backoffpolicy.StrategyCurve(s.strategy, s.delay)
+}
+
+// Overrides holds retry settings applied to requests for a specific host.
+// Zero-valued fields inherit the client configuration. Setting Delay or Strategy replaces
+// a custom curve set with WithBackoffCurve.
+type Overrides struct {
+	Attempts uint32
+	Delay    time.Duration
+	Strategy backoffpolicy.Strategy
+	Policy   Policy
+}
+
+// WithHostOverride applies overrides to every request whose URL host matches host, either
+// exactly (including the port) or by hostname, so one client can follow each upstream's retry guidance.
+func WithHostOverride(host string, overrides Overrides) ClientOption {
+	return func(c *Client) error {
+		if host == "" {
+			return fmt.Errorf("empty host")
+		}
+		if overrides.Delay < 0 || overrides.Delay > backoffpolicy.MaxDelay {
+			return fmt.Errorf("invalid delay value '%s' for host '%s'", overrides.Delay, host)
+		}
+		if overrides.Strategy != "" {
+			strategy, err := backoffpolicy.ParseStrategy(string(overrides.Strategy))
+			if err != nil {
+				return fmt.Errorf("%w for host '%s'", err, host)
+			}
+			overrides.Strategy = strategy
+		}
+		c.hostOverrides[strings.ToLower(host)] = overrides
+
+		return nil
+	}
+}
+
+// WithMethodPolicy sets the retry policy used for requests with the given HTTP method,
+// overriding the client-wide policy.
+func WithMethodPolicy(method string, policy Policy) ClientOption {
+	return func(c *Client) error {
+		if method == "" {
+			return fmt.Errorf("empty method")
+		}
+		if policy == nil {
+			return fmt.Errorf("nil policy for method '%s'", method)
+		}
+		c.methodPolicies[strings.ToUpper(method)] = policy
+
+		return nil
+	}
+}
+
+// WithMethodAttempts sets the number of attempts used for requests with the given HTTP method,
+// overriding the client-wide attempts.
+func WithMethodAttempts(method string, attempts uint32) ClientOption {
+	return func(c *Client) error {
+		if method == "" {
+			return fmt.Errorf("empty method")
+		}
+		if attempts < 1 {
+			return fmt.Errorf("invalid attempts value '%d'", attempts)
+		}
+		c.methodAttempts[strings.ToUpper(method)] = attempts
+
+		return nil
+	}
+}
+
+// hostOverride returns the overrides configured for the request host, if any.
+func (c *Client) hostOverride(req *http.Request) (Overrides, bool) {
+	if o, ok := c.hostOverrides[strings.ToLower(req.URL.Host)]; ok {
+		return o, true
+	}
+
+	o, ok := c.hostOverrides[strings.ToLower(req.URL.Hostname())]
+
+	return o, ok
+}
+
+// defaultSettings returns the client-wide retry settings.
+func (c *Client) defaultSettings() retrySettings {
+	return retrySettings{
+		attempts: c.attempts,
+		delay:    c.delay,
+		strategy: c.strategy,
+		curve:    c.curve,
+		policy:   c.policy,
+	}
+}
+
+// settingsFor resolves the retry settings for req. Method overrides are applied on top of the
+// client defaults, host overrides on top of those, and the options of the request, if any, last. The
+// adaptive attempt budget caps whatever was resolved.
+func (c *Client) settingsFor(req *http.Request, ro *requestOptions) retrySettings {
+	s := c.defaultSettings()
+
+	method := strings.ToUpper(req.Method)
+	if policy, ok := c.methodPolicies[method]; ok {
+		s.policy = policy
+	}
+	if attempts, ok := c.methodAttempts[method]; ok {
+		s.attempts = attempts
+	}
+
+	if o, ok := c.hostOverride(req); ok {
+		if o.Attempts > 0 {
+			s.attempts = o.Attempts
+		}
+		if o.Delay > 0 {
+			s.delay, s.curve = o.Delay, nil
+		}
+		if o.Strategy != "" {
+			s.strategy, s.curve = o.Strategy, nil
+		}
+		if o.Policy != nil {
+			s.policy = o.Policy
+		}
+	}
+
+	if ro != nil {
+		if ro.attempts > 0 {
+			s.attempts = ro.attempts
+		}
+		if ro.policy != nil {
+			s.policy = ro.policy
+		}
+	}
+
+	// Scale the attempt budget down while the host is failing, if adaptive retries are enabled.
+	if c.adaptive != nil {
+		s.attempts = c.adaptive.attempts(req.URL.Host, s.attempts)
+	}
+
+	return s
+}
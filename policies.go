@@ -0,0 +1,77 @@
+package retryablehttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+var (
+	// PolicyStrict2xx retries every transport error except permanent ones (see IsPermanentError),
+	// and every response without a 2xx status code. It's the default policy.
+	PolicyStrict2xx Policy = func(resp *http.Response, err error) error {
+		if IsPermanentError(err) {
+			return backoffpolicy.Permanent(fmt.Errorf("permanent error: %w", err))
+		}
+		if err != nil {
+			return fmt.Errorf("propagating error: %w", err)
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("HTTP response status code (%d) outside boundaries", resp.StatusCode)
+		}
+
+		return nil
+	}
+
+	// PolicyIdempotentOnly follows PolicyStrict2xx for idempotent methods (GET, HEAD, OPTIONS, TRACE,
+	// PUT and DELETE), and never retries other methods, as repeating them could apply them twice.
+	PolicyIdempotentOnly Policy = func(resp *http.Response, err error) error {
+		perr := PolicyStrict2xx(resp, err)
+		if perr != nil && !isIdempotent(attemptMethod(resp, err)) {
+			return backoffpolicy.Permanent(perr)
+		}
+
+		return perr
+	}
+
+	// PolicyRetryServerErrors retries transport errors and 5xx responses, and returns any other
+	// response to the caller, e.g. 4xx responses that retrying wouldn't fix.
+	PolicyRetryServerErrors = RetryIf(Any(RetryOn5xx, RetryOnNetworkError))
+
+	// PolicyNever never retries: every response is returned to the caller and transport errors fail
+	// the request immediately.
+	PolicyNever Policy = func(resp *http.Response, err error) error {
+		return backoffpolicy.Permanent(err)
+	}
+)
+
+// attemptMethod returns the HTTP method of the attempt that produced the outcome, or an empty string
+// if it can't be determined.
+func attemptMethod(resp *http.Response, err error) string {
+	if resp != nil && resp.Request != nil {
+		return resp.Request.Method
+	}
+
+	// Transport errors name the method of the request in their operation, e.g. "Post".
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return strings.ToUpper(urlErr.Op)
+	}
+
+	return ""
+}
+
+// isIdempotent reports whether repeating a request with method has the same effect as sending it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
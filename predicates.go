@@ -0,0 +1,92 @@
+package retryablehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// Policy inspects the outcome of an attempt: returning an error retries the request,
+// returning nil accepts the response.
+type Policy func(resp *http.Response, err error) error
+
+// Predicate reports whether the outcome of an attempt should be retried.
+type Predicate func(resp *http.Response, err error) bool
+
+// RetryIf builds a Policy from a predicate. Outcomes matching the predicate are retried; any
+// other response is accepted, while a transport error that doesn't match fails without retrying.
+func RetryIf(predicate Predicate) Policy {
+	return func(resp *http.Response, err error) error {
+		if !predicate(resp, err) {
+			return backoffpolicy.Permanent(err)
+		}
+
+		if err != nil {
+			return fmt.Errorf("propagating error: %w", err)
+		}
+
+		return fmt.Errorf("HTTP response status code (%d) matched retry predicate", resp.StatusCode)
+	}
+}
+
+// RetryOn5xx matches responses with a server error status code.
+func RetryOn5xx(resp *http.Response, err error) bool {
+	return err == nil && resp != nil && resp.StatusCode >= 500 && resp.StatusCode <= 599
+}
+
+// RetryOnNetworkError matches attempts that failed without a response, except when the
+// request context was cancelled or the error is permanent, see IsPermanentError.
+func RetryOnNetworkError(resp *http.Response, err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled) && !IsPermanentError(err)
+}
+
+// RetryOnStatuses matches responses with one of the given status codes.
+func RetryOnStatuses(codes ...int) Predicate {
+	return func(resp *http.Response, err error) bool {
+		return err == nil && resp != nil && slices.Contains(codes, resp.StatusCode)
+	}
+}
+
+// Not negates a predicate.
+func Not(predicate Predicate) Predicate {
+	return func(resp *http.Response, err error) bool {
+		return !predicate(resp, err)
+	}
+}
+
+// Any matches when at least one of the predicates matches.
+func Any(predicates ...Predicate) Predicate {
+	return func(resp *http.Response, err error) bool {
+		for _, p := range predicates {
+			if p(resp, err) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// All matches when every predicate matches.
+func All(predicates ...Predicate) Predicate {
+	return func(resp *http.Response, err error) bool {
+		for _, p := range predicates {
+			if !p(resp, err) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// isPermanent reports whether err stops retries without further attempts.
+func isPermanent(err error) bool {
+	var permanent *backoffpolicy.PermanentError
+
+	return errors.As(err, &permanent)
+}
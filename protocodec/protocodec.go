@@ -0,0 +1,37 @@
+// Package protocodec provides a retryablehttp codec for Protocol Buffers bodies.
+package protocodec // import "github.com/condrove10/retryablehttp/protocodec"
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type handled by Codec.
+const ContentType = "application/x-protobuf"
+
+// Codec encodes proto.Message values in the Protocol Buffers binary format. Register it with
+// retryablehttp.WithCodec(protocodec.Codec{}) and send requests with a Content-Type of ContentType.
+type Codec struct{}
+
+func (Codec) ContentType() string {
+	return ContentType
+}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("value of type %T isn't a proto.Message", v)
+	}
+
+	return proto.Marshal(m)
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("value of type %T isn't a proto.Message", v)
+	}
+
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,110 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// proxyAuth holds the credentials presented to proxies, fetched again once a proxy refuses them.
+type proxyAuth struct {
+	fetch func(ctx context.Context) (username, password string, err error)
+
+	mu   sync.Mutex
+	user *url.Userinfo
+}
+
+// WithProxyAuth authenticates to proxies with the given username and password, sent as basic
+// Proxy-Authorization both on CONNECT requests and on plain-HTTP requests forwarded by the proxy.
+// Proxy URLs carrying their own credentials keep them. It requires the managed transport.
+func WithProxyAuth(username, password string) ClientOption {
+	return WithProxyCredentials(func(context.Context) (string, string, error) {
+		return username, password, nil
+	})
+}
+
+// WithProxyCredentials authenticates to proxies like WithProxyAuth, with credentials returned by fetch.
+// They're cached until a proxy answers 407 Proxy Authentication Required, after which fetch is called
+// again for the next attempt. A change of credentials opens new connections, re-dialling the tunnels
+// through the proxy.
+func WithProxyCredentials(fetch func(ctx context.Context) (username, password string, err error)) ClientOption {
+	return func(c *Client) error {
+		if fetch == nil {
+			return fmt.Errorf("nil proxy credentials function")
+		}
+		c.proxyAuth = &proxyAuth{fetch: fetch}
+		c.transportConfigured = true
+
+		return nil
+	}
+}
+
+// userinfo returns the cached credentials, fetching them if needed.
+func (p *proxyAuth) userinfo(ctx context.Context) (*url.Userinfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.user == nil {
+		username, password, err := p.fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch proxy credentials: %w", err)
+		}
+		p.user = url.UserPassword(username, password)
+	}
+
+	return p.user, nil
+}
+
+// invalidate drops the cached credentials after a proxy refused them.
+func (p *proxyAuth) invalidate() {
+	p.mu.Lock()
+	p.user = nil
+	p.mu.Unlock()
+}
+
+// wrap returns a proxy function adding the credentials to the proxy URLs chosen by proxy. The transport
+// sends the credentials of the proxy URL as Proxy-Authorization, and pools connections per proxy URL.
+func (p *proxyAuth) wrap(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return nil
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil || u.User != nil {
+			return u, err
+		}
+
+		user, err := p.userinfo(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		withUser := *u
+		withUser.User = user
+
+		return &withUser, nil
+	}
+}
+
+// proxyAuthorization returns the basic Proxy-Authorization value for user.
+func proxyAuthorization(user *url.Userinfo) string {
+	password, _ := user.Password()
+	probe := &http.Request{Header: http.Header{}}
+	probe.SetBasicAuth(user.Username(), password)
+
+	return probe.Header.Get("Authorization")
+}
+
+// proxyAuthRequired reports whether a proxy refused an attempt with 407 Proxy Authentication Required,
+// either as the response to a forwarded request or by failing the CONNECT request of a tunnel, which
+// net/http reports as an error carrying the status text.
+func proxyAuthRequired(resp *http.Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode == http.StatusProxyAuthRequired
+	}
+
+	return err != nil && strings.Contains(err.Error(), http.StatusText(http.StatusProxyAuthRequired))
+}
@@ -0,0 +1,59 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// WithDefaultQueryParams adds params to the query of every request URL, e.g. an API key or an API
+// version, unless the URL already sets them. A single request can override them with WithQueryParams.
+func WithDefaultQueryParams(params map[string]string) ClientOption {
+	return func(c *Client) error {
+		if len(params) == 0 {
+			return fmt.Errorf("empty default query params")
+		}
+		for k := range params {
+			if k == "" {
+				return fmt.Errorf("empty query param name")
+			}
+		}
+		c.queryParams = maps.Clone(params)
+
+		return nil
+	}
+}
+
+// WithQueryParams sets params in the query of a single request URL, replacing the values the URL or
+// the client defaults give them.
+func WithQueryParams(params map[string]string) RequestOption {
+	return func(ro *requestOptions) error {
+		for k := range params {
+			if k == "" {
+				return fmt.Errorf("empty query param name")
+			}
+		}
+		ro.queryParams = maps.Clone(params)
+
+		return nil
+	}
+}
+
+// applyQueryParams merges the client default query params and the request ones into the URL of req,
+// leaving its query as is when there's nothing to change.
+func (c *Client) applyQueryParams(req *http.Request, overrides map[string]string) {
+	if len(c.queryParams) == 0 && len(overrides) == 0 {
+		return
+	}
+
+	query := req.URL.Query()
+	for k, v := range c.queryParams {
+		if !query.Has(k) {
+			query.Set(k, v)
+		}
+	}
+	for k, v := range overrides {
+		query.Set(k, v)
+	}
+	req.URL.RawQuery = query.Encode()
+}
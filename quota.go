@@ -0,0 +1,72 @@
+package retryablehttp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded reports that a request was refused by the quota configured with WithQuota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaExceededError is returned for requests refused by the quota, before any attempt is sent.
+// It matches ErrQuotaExceeded with errors.Is.
+type QuotaExceededError struct {
+	Limit  uint32
+	Window time.Duration
+	// RetryAfter is the time left until the quota resets.
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota of %d requests per %s exceeded, resets in %s", e.Limit, e.Window, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// quota counts requests in fixed windows.
+type quota struct {
+	limit  uint32
+	window time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	used  uint32
+}
+
+// WithQuota limits the client to n requests per window, counted per request rather than per attempt.
+// Requests over the quota fail right away with a *QuotaExceededError. Giving each tenant its own client,
+// e.g. through a Registry, keeps noisy tenants from starving the others.
+func WithQuota(n uint32, window time.Duration) ClientOption {
+	return func(c *Client) error {
+		if n == 0 {
+			return fmt.Errorf("invalid quota value '%d'", n)
+		}
+		if window <= 0 {
+			return fmt.Errorf("invalid quota window value '%s'", window)
+		}
+		c.quota = &quota{limit: n, window: window}
+
+		return nil
+	}
+}
+
+// take counts a request against the quota, or reports that the quota is exceeded.
+func (q *quota) take() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(q.start) >= q.window {
+		q.start, q.used = now, 0
+	}
+	if q.used >= q.limit {
+		return &QuotaExceededError{Limit: q.limit, Window: q.window, RetryAfter: q.start.Add(q.window).Sub(now)}
+	}
+	q.used++
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package retryablehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixTimestampThreshold separates X-RateLimit-Reset values expressed as a unix timestamp
+// from values expressed as a number of seconds until the reset.
+const unixTimestampThreshold = 1_000_000_000
+
+// WithRateLimitHeaders makes the client honour rate limit headers on 429 and 503 responses:
+// the next attempt is delayed until the announced reset (Retry-After, RateLimit-Reset,
+// RateLimit and X-RateLimit-Reset). If the reset is further away than maxWait, the request
+// fails immediately instead of retrying into a guaranteed rejection.
+func WithRateLimitHeaders(maxWait time.Duration) ClientOption {
+	return func(c *Client) error {
+		if maxWait <= 0 {
+			return fmt.Errorf("invalid rate limit max wait value '%s'", maxWait)
+		}
+		c.rateLimitMaxWait = maxWait
+
+		return nil
+	}
+}
+
+// rateLimitWait returns how long to wait before retrying a rate limited response, if the
+// response announces it.
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		return wait, true
+	}
+
+	// Rate limit reset headers only carry meaning for 429 responses.
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	return parseRateLimitReset(resp.Header, now)
+}
+
+// parseRateLimitReset returns how long until the rate limit window announced by header resets, from
+// RateLimit-Reset, the structured RateLimit header or X-RateLimit-Reset, in that order.
+func parseRateLimitReset(header http.Header, now time.Time) (time.Duration, bool) {
+	if wait, ok := parseDeltaSeconds(header.Get("RateLimit-Reset")); ok {
+		return wait, true
+	}
+
+	if wait, ok := parseRateLimitField(header.Get("RateLimit"), "reset"); ok {
+		return wait, true
+	}
+
+	if v := strings.TrimSpace(header.Get("X-RateLimit-Reset")); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+
+		if seconds >= unixTimestampThreshold {
+			wait := time.Unix(int64(seconds), 0).Sub(now)
+			return max(wait, 0), true
+		}
+
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After header value, either delay-seconds or an HTTP-date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if wait, ok := parseDeltaSeconds(v); ok {
+		return wait, true
+	}
+
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(at.Sub(now), 0), true
+}
+
+// parseDeltaSeconds parses a non-negative number of seconds.
+func parseDeltaSeconds(v string) (time.Duration, bool) {
+	seconds, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// parseRateLimitField extracts a delta-seconds parameter from a structured RateLimit header
+// such as "limit=100, remaining=0, reset=30".
+func parseRateLimitField(v, name string) (time.Duration, bool) {
+	value, ok := rateLimitParam(v, name)
+	if !ok {
+		return 0, false
+	}
+
+	return parseDeltaSeconds(value)
+}
+
+// rateLimitParam returns the raw value of a parameter of a structured RateLimit header.
+func rateLimitParam(v, name string) (string, bool) {
+	for _, part := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// HostQuota is the quota of an upstream host, as announced by the headers of its latest response
+// carrying any of them: X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset, their
+// RateLimit-* counterparts, the structured RateLimit header and Retry-After.
+type HostQuota struct {
+	// Limit is the number of requests allowed per window, or -1 if unknown.
+	Limit int64
+	// Remaining is the number of requests left in the window, or -1 if unknown.
+	Remaining int64
+	// Reset is when the window resets, zero if unknown.
+	Reset time.Time
+	// RetryAfter is when the host asked to be retried, zero if it didn't.
+	RetryAfter time.Time
+	// UpdatedAt is when the response announcing the quota was received.
+	UpdatedAt time.Time
+}
+
+// hostQuotas holds the latest quota announced by each host, and drops those of the hosts left idle.
+type hostQuotas struct {
+	mu      sync.Mutex
+	quotas  map[string]HostQuota
+	sweeper idleSweeper
+}
+
+// QuotaFor returns the latest quota announced by host, given with its port if the requests carry one,
+// so applications can pace their work before running into 429 responses. The quota of a host that
+// announced none for a minute is forgotten once its window has reset.
+func (c *Client) QuotaFor(host string) (HostQuota, bool) {
+	c.hostQuotas.mu.Lock()
+	defer c.hostQuotas.mu.Unlock()
+
+	q, ok := c.hostQuotas.quotas[strings.ToLower(host)]
+
+	return q, ok
+}
+
+// record stores the quota announced by the headers of resp, if any.
+func (h *hostQuotas) record(host string, resp *http.Response, now time.Time) {
+	q, ok := parseHostQuota(resp.Header, now)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.quotas == nil {
+		h.quotas = map[string]HostQuota{}
+	}
+	if h.sweeper.due(now) {
+		for key, q := range h.quotas {
+			if now.Sub(q.UpdatedAt) > hostIdleTimeout && !q.Reset.After(now) && !q.RetryAfter.After(now) {
+				delete(h.quotas, key)
+			}
+		}
+	}
+	h.quotas[strings.ToLower(host)] = q
+}
+
+// parseHostQuota extracts the quota announced by header, reporting false if it announces none.
+func parseHostQuota(header http.Header, now time.Time) (HostQuota, bool) {
+	q := HostQuota{Limit: -1, Remaining: -1, UpdatedAt: now}
+	found := false
+
+	// Counts come from the dedicated headers, or else from the structured RateLimit header.
+	count := func(param string, names ...string) int64 {
+		values := make([]string, 0, len(names)+1)
+		for _, name := range names {
+			values = append(values, header.Get(name))
+		}
+		if value, ok := rateLimitParam(header.Get("RateLimit"), param); ok {
+			values = append(values, value)
+		}
+
+		for _, v := range values {
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && n >= 0 {
+				found = true
+				return n
+			}
+		}
+		return -1
+	}
+	q.Limit = count("limit", "X-RateLimit-Limit", "RateLimit-Limit")
+	q.Remaining = count("remaining", "X-RateLimit-Remaining", "RateLimit-Remaining")
+
+	if wait, ok := parseRateLimitReset(header, now); ok {
+		q.Reset, found = now.Add(wait), true
+	}
+
+	if wait, ok := parseRetryAfter(header.Get("Retry-After"), now); ok {
+		q.RetryAfter, found = now.Add(wait), true
+	}
+
+	return q, found
+}
+
+// sleepContext waits for d to elapse or for ctx to be done, whichever happens first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
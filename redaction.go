@@ -0,0 +1,146 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redactedValue replaces the redacted parts of bodies, as masked headers are in the logs.
+const redactedValue = "[masked]"
+
+// BodyRedaction lists the parts of bodies masked before they're exposed, see WithBodyRedaction.
+type BodyRedaction struct {
+	// JSONPaths lists the fields masked in JSON bodies, as dot-separated keys from the root, e.g.
+	// "user.email". A "*" segment matches any key or array element, e.g. "items.*.card", and a number
+	// matches an array element by index.
+	JSONPaths []string
+	// Patterns lists regular expressions whose matches are masked in any body, e.g. "\\b\\d{16}\\b" for
+	// card numbers.
+	Patterns []string
+}
+
+// bodyRedactor is a BodyRedaction compiled for matching.
+type bodyRedactor struct {
+	paths    [][]string
+	patterns []*regexp.Regexp
+	// fields matches the values of the fields named like the last segment of a path, masked when a body
+	// can't be parsed as JSON, e.g. because it was truncated.
+	fields *regexp.Regexp
+}
+
+// WithBodyRedaction masks the parts of response bodies matched by r before the client exposes them,
+// so debug tooling can be enabled without leaking personal data: the bodies captured with
+// WithErrorBodyCapture are redacted, and with them the errors logged, audited or returned. Bodies that
+// can't be parsed as JSON, e.g. because their capture was truncated, have the values of the fields
+// named like the last segment of a path masked wherever they appear. RedactBody applies the same rules
+// to other bodies, e.g. those of the snapshots given to WithOnExhausted, which are kept intact so they
+// can be replayed.
+func WithBodyRedaction(r BodyRedaction) ClientOption {
+	return func(c *Client) error {
+		if len(r.JSONPaths) == 0 && len(r.Patterns) == 0 {
+			return fmt.Errorf("empty body redaction")
+		}
+
+		redactor := &bodyRedactor{}
+		var names []string
+		for _, path := range r.JSONPaths {
+			segments := strings.Split(path, ".")
+			for _, segment := range segments {
+				if segment == "" {
+					return fmt.Errorf("invalid redaction json path '%s'", path)
+				}
+			}
+			redactor.paths = append(redactor.paths, segments)
+			if name := segments[len(segments)-1]; name != "*" {
+				names = append(names, regexp.QuoteMeta(name))
+			}
+		}
+		for _, pattern := range r.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid redaction pattern '%s': %w", pattern, err)
+			}
+			redactor.patterns = append(redactor.patterns, re)
+		}
+		if len(names) > 0 {
+			redactor.fields = regexp.MustCompile(`("(?:` + strings.Join(names, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+		}
+		c.redactor = redactor
+
+		return nil
+	}
+}
+
+// RedactBody returns a copy of body with the parts matched by the rules of WithBodyRedaction masked, or
+// body itself if none is configured.
+func (c *Client) RedactBody(body []byte) []byte {
+	return c.redactor.redact(body)
+}
+
+// redact masks the JSON paths of body, then the matches of the patterns. A nil redactor returns body.
+func (r *bodyRedactor) redact(body []byte) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+
+	if len(r.paths) > 0 {
+		body = r.redactJSON(body)
+	}
+	for _, re := range r.patterns {
+		body = re.ReplaceAllLiteral(body, []byte(redactedValue))
+	}
+
+	return body
+}
+
+// redactJSON masks the JSON paths of body, or the fields named like them if body isn't valid JSON.
+func (r *bodyRedactor) redactJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil || decoder.More() {
+		if r.fields == nil {
+			return body
+		}
+		return r.fields.ReplaceAll(body, []byte(`${1}"`+redactedValue+`"`))
+	}
+
+	for _, path := range r.paths {
+		doc = redactPath(doc, path)
+	}
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+
+	return redacted
+}
+
+// redactPath masks the values found at path in v.
+func redactPath(v any, path []string) any {
+	if len(path) == 0 {
+		return redactedValue
+	}
+
+	segment, rest := path[0], path[1:]
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if segment == "*" || segment == k {
+				v[k] = redactPath(child, rest)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				v[i] = redactPath(child, rest)
+			}
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,85 @@
+// Package redislimiter implements retryablehttp.Limiter on Redis, so a fleet of instances collectively
+// respects the global rate limit of a vendor rather than each instance limiting on its own.
+package redislimiter // import "github.com/condrove10/retryablehttp/redislimiter"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcra implements the generic cell rate algorithm on the theoretical arrival time (TAT) of the next
+// attempt, held in KEYS[1] and in microseconds of the Redis clock, so the instances sharing it don't
+// depend on their own clocks. ARGV[1] is the interval between attempts and ARGV[2] the burst tolerance,
+// both in microseconds. It returns 0 when the attempt may be sent, having taken its place, or else the
+// time to wait before trying again.
+var gcra = redis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local tat = math.max(tonumber(redis.call('GET', KEYS[1]) or now), now)
+local allowed_at = tat - tolerance
+if allowed_at > now then
+	return allowed_at - now
+end
+
+tat = tat + interval
+redis.call('SET', KEYS[1], string.format('%d', tat), 'PX', math.ceil((tat - now) / 1000))
+return 0
+`)
+
+// Limiter shapes the traffic sent for each key to rps attempts per second with bursts of up to burst
+// attempts, across every instance sharing the same Redis and prefix.
+type Limiter struct {
+	client    redis.Scripter
+	prefix    string
+	interval  time.Duration
+	tolerance time.Duration
+}
+
+// New returns a Limiter storing its state in client under keys starting with prefix, e.g.
+// "ratelimit:vendor:".
+func New(client redis.Scripter, prefix string, rps float64, burst int) (*Limiter, error) {
+	if client == nil {
+		return nil, fmt.Errorf("nil redis client")
+	}
+	if rps <= 0 {
+		return nil, fmt.Errorf("invalid rate limit value '%g'", rps)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("invalid burst value '%d'", burst)
+	}
+
+	interval := time.Duration(float64(time.Second) / rps)
+	return &Limiter{
+		client:    client,
+		prefix:    prefix,
+		interval:  interval,
+		tolerance: time.Duration(burst-1) * interval,
+	}, nil
+}
+
+// Wait blocks until an attempt keyed by key may be sent or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	for {
+		wait, err := gcra.Run(ctx, l.client, []string{l.prefix + key}, l.interval.Microseconds(), l.tolerance.Microseconds()).Int64()
+		if err != nil {
+			return fmt.Errorf("failed to run redis rate limit script: %w", err)
+		}
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration(wait) * time.Microsecond)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
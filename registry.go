@@ -0,0 +1,203 @@
+package retryablehttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// ClientConfig is the configuration of a named client in a registry document. Zero-valued fields keep
+// the client defaults.
+type ClientConfig struct {
+	Attempts uint32 `json:"attempts,omitempty"`
+	// Delay is the base delay between attempts, as a duration like "500ms".
+	Delay                 string                 `json:"delay,omitempty"`
+	Strategy              backoffpolicy.Strategy `json:"strategy,omitempty"`
+	Rules                 []RetryRule            `json:"rules,omitempty"`
+	Endpoints             []string               `json:"endpoints,omitempty"`
+	UserAgent             string                 `json:"userAgent,omitempty"`
+	MaxConcurrentRequests uint32                 `json:"maxConcurrentRequests,omitempty"`
+	Quota                 *QuotaConfig           `json:"quota,omitempty"`
+}
+
+// QuotaConfig is the request quota of a client in a registry document, see WithQuota.
+type QuotaConfig struct {
+	Requests uint32 `json:"requests"`
+	// Window is the quota window, as a duration like "1m".
+	Window string `json:"window"`
+}
+
+// options returns the client options described by the configuration.
+func (cfg ClientConfig) options() ([]ClientOption, error) {
+	var opts []ClientOption
+
+	if cfg.Attempts != 0 {
+		opts = append(opts, WithAttempts(cfg.Attempts))
+	}
+	if cfg.Delay != "" {
+		delay, err := time.ParseDuration(cfg.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay value '%s'", cfg.Delay)
+		}
+		opts = append(opts, WithDelay(delay))
+	}
+	if cfg.Strategy != "" {
+		opts = append(opts, WithStrategy(cfg.Strategy))
+	}
+	if len(cfg.Rules) > 0 {
+		opts = append(opts, WithRetryRules(cfg.Rules...))
+	}
+	if len(cfg.Endpoints) > 0 {
+		opts = append(opts, WithEndpoints(cfg.Endpoints...))
+	}
+	if cfg.UserAgent != "" {
+		opts = append(opts, WithUserAgent(cfg.UserAgent))
+	}
+	if cfg.MaxConcurrentRequests != 0 {
+		opts = append(opts, WithMaxConcurrentRequests(cfg.MaxConcurrentRequests))
+	}
+
+	if cfg.Quota != nil {
+		window, err := time.ParseDuration(cfg.Quota.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota window value '%s'", cfg.Quota.Window)
+		}
+		opts = append(opts, WithQuota(cfg.Quota.Requests, window))
+	}
+
+	return opts, nil
+}
+
+// Registry manages named clients, e.g. one per vendor or tenant, built from a single configuration
+// document such as
+//
+//	{"payments": {"attempts": 5, "delay": "200ms", "strategy": "exponential"},
+//	 "geocoding": {"endpoints": ["https://eu.geo.example.com", "https://us.geo.example.com"]},
+//	 "tenant-acme": {"quota": {"requests": 1000, "window": "1m"}}}
+//
+// Clients are built on first use and share a single transport, hence its connection pool; the options
+// configuring the managed transport can't be used with them.
+type Registry struct {
+	ctx        context.Context
+	httpClient *http.Client
+	common     []ClientOption
+
+	mu      sync.Mutex
+	configs map[string][]ClientOption
+	clients map[string]*Client
+}
+
+// NewRegistry returns a registry of the clients described by the JSON document data, an object mapping
+// names to ClientConfig. The configurations are validated right away. opts apply to every client,
+// before its configuration.
+func NewRegistry(ctx context.Context, data []byte, opts ...ClientOption) (*Registry, error) {
+	var configs map[string]ClientConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to decode registry configuration: %w", err)
+	}
+
+	r := &Registry{
+		ctx:        ctx,
+		httpClient: &http.Client{Transport: newManagedTransport()},
+		common:     opts,
+		configs:    map[string][]ClientOption{},
+		clients:    map[string]*Client{},
+	}
+	for name, cfg := range configs {
+		clientOpts, err := cfg.options()
+		if err == nil {
+			// Validate the options on a throwaway client built like Client builds it, so configuration
+			// errors, including conflicts with the common options, surface now.
+			var c *Client
+			if c, err = New(ctx, r.clientOptions(clientOpts)...); err == nil {
+				c.Close()
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration for client '%s': %w", name, err)
+		}
+		r.configs[name] = clientOpts
+	}
+
+	return r, nil
+}
+
+// Configure adds options to a named client, applied after its configuration, e.g. to set credentials
+// that don't belong in the document. It fails once the client has been built.
+func (r *Registry) Configure(name string, opts ...ClientOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.configs[name]; !ok {
+		return fmt.Errorf("unknown client '%s'", name)
+	}
+	if _, ok := r.clients[name]; ok {
+		return fmt.Errorf("client '%s' already built", name)
+	}
+	r.configs[name] = append(r.configs[name], opts...)
+
+	return nil
+}
+
+// Client returns the named client, building it on first use.
+func (r *Registry) Client(name string) (*Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.clients[name]; ok {
+		return c, nil
+	}
+	opts, ok := r.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown client '%s'", name)
+	}
+
+	c, err := New(r.ctx, r.clientOptions(opts)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build client '%s': %w", name, err)
+	}
+	r.clients[name] = c
+
+	return c, nil
+}
+
+// clientOptions returns the options a client is built with: the shared http client, the common
+// options, then opts.
+func (r *Registry) clientOptions(opts []ClientOption) []ClientOption {
+	all := append([]ClientOption{WithHttpClient(r.httpClient)}, r.common...)
+
+	return append(all, opts...)
+}
+
+// Names returns the sorted names of the clients in the registry.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Close closes every client built by the registry, and the idle connections of the shared transport.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.clients {
+		c.Close()
+	}
+	r.httpClient.CloseIdleConnections()
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WithReturnLastResponse makes requests that fail after receiving a response return the last
+// response received alongside the error, instead of a nil response, so callers can inspect the status
+// and error payload the server sent. The rejected responses are read into memory to outlive their
+// connection; the returned response still has to be closed.
+func WithReturnLastResponse(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.returnLastResponse = enabled
+
+		return nil
+	}
+}
+
+// bufferBody reads the body of resp into memory and closes it, replacing it with the bytes read, or
+// rewinds it if it's already buffered. A body that fails to read is replaced with the part read before
+// the failure.
+func bufferBody(resp *http.Response) error {
+	if b, ok := resp.Body.(*BufferedBody); ok {
+		_, err := b.Seek(0, io.SeekStart)
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = newBufferedBody(body)
+
+	return err
+}
+
+// WithBufferResponse makes the client read the body of every response into memory and close it before
+// deciding on the attempt, so the policy, validators and hooks can inspect the body, and callers no longer
+// have to close responses to free their connection. A body that fails to read fails the attempt, which is
+// retried. Responses are returned with a *BufferedBody, unless wrapped by other options.
+func WithBufferResponse(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.bufferResponse = enabled
+
+		return nil
+	}
+}
+
+// BufferedBody is a response body read into memory. Reading it can start over after seeking back to
+// the start, Bytes returns the whole body without consuming it, and closing it is a no-op.
+type BufferedBody struct {
+	*bytes.Reader
+	body []byte
+}
+
+func newBufferedBody(body []byte) *BufferedBody {
+	return &BufferedBody{Reader: bytes.NewReader(body), body: body}
+}
+
+// Bytes returns the whole body, regardless of how much of it was read.
+func (b *BufferedBody) Bytes() []byte {
+	return b.body
+}
+
+func (b *BufferedBody) Close() error {
+	return nil
+}
+
+// bufferResponseBody reads the body of resp into memory, replacing it with a BufferedBody, and returns
+// its content. A body that is already buffered is returned as is.
+func bufferResponseBody(resp *http.Response) ([]byte, error) {
+	if b, ok := resp.Body.(*BufferedBody); ok {
+		return b.Bytes(), nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = newBufferedBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return body, nil
+}
+
+// ResponseError describes a response rejected by the policy, as captured with WithErrorBodyCapture.
+type ResponseError struct {
+	StatusCode int
+	Header     http.Header
+	// Body holds the start of the response body, up to the capture limit, redacted as configured with
+	// WithBodyRedaction.
+	Body []byte
+	// Truncated reports whether the body was longer than the capture limit.
+	Truncated bool
+	Err       error
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Body) == 0 {
+		return e.Err.Error()
+	}
+
+	truncated := ""
+	if e.Truncated {
+		truncated = " (truncated)"
+	}
+
+	return fmt.Sprintf("%s: response body%s: %q", e.Err, truncated, e.Body)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorBodyCapture attaches the status, headers and up to limit bytes of the body of responses
+// rejected by the policy to the error of the attempt, as a *ResponseError, since error payloads are
+// often the only way to tell why a request failed.
+func WithErrorBodyCapture(limit int64) ClientOption {
+	return func(c *Client) error {
+		if limit <= 0 {
+			return fmt.Errorf("invalid error body capture limit value '%d'", limit)
+		}
+		c.errorBodyLimit = limit
+
+		return nil
+	}
+}
+
+// captureResponse wraps the error of a rejected response with its status, headers and the start
+// of its body, redacted by redactor. The body of resp is left intact for the caller.
+func captureResponse(resp *http.Response, err error, limit int64, redactor *bodyRedactor) error {
+	captured, _ := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(captured), resp.Body), resp.Body}
+
+	truncated := int64(len(captured)) > limit
+	if truncated {
+		captured = captured[:limit]
+	}
+
+	return &ResponseError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       redactor.redact(captured),
+		Truncated:  truncated,
+		Err:        err,
+	}
+}
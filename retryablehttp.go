@@ -3,10 +3,14 @@ package retryablehttp
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
-	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/condrove10/retryablehttp/backoffpolicy"
@@ -16,44 +20,136 @@ import (
 // ClientOption represents a functional option for configuring the retryable HTTP client.
 type ClientOption func(*Client) error
 
+// RequestOption represents a functional option for configuring a single request.
+type RequestOption func(*requestOptions) error
+
+// requestOptions holds the per-request configuration set through RequestOption values.
+type requestOptions struct {
+	ctx          context.Context
+	basicAuth    *credentials
+	bodyProvider func() (io.ReadCloser, error)
+	trailer      http.Header
+	onTrailers   func(trailer http.Header)
+	accept       string
+	contentTypes []string
+	attempts     uint32
+	policy       Policy
+	tee          io.Writer
+	host         string
+	queryParams  map[string]string
+	slots        semaphore
+}
+
 // Client represents an HTTP client that automatically retries requests on failures.
 type Client struct {
 	context    context.Context
 	httpClient *http.Client
+	transport  *http.Transport
 	attempts   uint32
 	delay      time.Duration
 	strategy   backoffpolicy.Strategy
-	policy     func(resp *http.Response, err error) error
+	curve      *backoffpolicy.Curve
+	policy     Policy
+
+	customHttpClient    bool
+	transportConfigured bool
+	expectContinue      bool
+
+	concurrency      semaphore
+	hostConcurrency  *hostSemaphores
+	adaptive         *adaptiveRetry
+	latency          *latencyTracker
+	retryStatuses    []int
+	noRetryStatuses  []int
+	rules            []compiledRule
+	methodPolicies   map[string]Policy
+	methodAttempts   map[string]uint32
+	hostOverrides    map[string]Overrides
+	hostRateLimits   map[string]*tokenBucket
+	endpoints        *endpointPool
+	healthCheck      *healthCheck
+	outlierDetection *OutlierDetection
+	endpointWeights  map[string]uint32
+	codecs           map[string]Codec
+
+	rateLimitMaxWait time.Duration
+	auth             Authenticator
+	signer           Signer
+	contextHeaders   func(ctx context.Context) map[string]string
+	userAgent        string
+	deadlineHeader   string
+
+	requestIDHeader    string
+	requestIDGenerator func() string
+
+	lifecycle          lifecycle
+	stats              clientStats
+	events             chan<- AttemptEvent
+	onExhausted        func(req RequestSnapshot, errs []error)
+	returnLastResponse bool
+	errorBodyLimit     int64
+	attemptTracing     bool
+	onTimingReport     func(TimingReport)
+	callbacks          semaphore
+	quota              *quota
+	hostQuotas         hostQuotas
+	responseValidator  func(resp *http.Response, body []byte) error
+	freshConnections   *freshConnections
+	proxyAuth          *proxyAuth
+	verifier           Verifier
+	integrityCheck     bool
+	bodyReadTimeout    time.Duration
+	timeout            time.Duration
+	bufferResponse     bool
+	hostHeader         string
+	logger             Logger
+	debug              atomic.Bool
+	logSampling        map[slog.Level]*logSampler
+	retryDamping       *retryDamping
+	limiter            Limiter
+	limiterKey         func(req *http.Request) string
+	transforms         []ResponseTransform
+	routeTransforms    *routeTransforms
+	attemptMutator     func(attempt uint32, req *http.Request) error
+	queryParams        map[string]string
+	hostStats          *hostStatsTracker
+	aimdLimits         *adaptiveConcurrency
+	loadProbes         []LoadProbe
+	audit              *auditor
+	redactor           *bodyRedactor
+	dialer             *net.Dialer
 }
 
 var (
-	defaultHttpClient        = http.DefaultClient
-	defaultAttemps    uint32 = 10
-	defaultDelay             = time.Second
-	defaultStrategy          = backoffpolicy.StrategyLinear
-	defaultPolicy            = func(resp *http.Response, err error) error {
-		if err != nil {
-			return fmt.Errorf("propagating error: %w", err)
-		}
-
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			return fmt.Errorf("HTTP response status code (%d) outside boundaries", resp.StatusCode)
-		}
-
-		return nil
-	}
+	defaultAttemps  uint32 = 10
+	defaultDelay           = time.Second
+	defaultStrategy        = backoffpolicy.StrategyLinear
+	defaultPolicy          = PolicyStrict2xx
 )
 
 // New creates and returns a new Client instance configured with the provided options.
 // The default client configuration is used if none is specified.
 func New(ctx context.Context, opts ...ClientOption) (*Client, error) {
+	transport := newManagedTransport()
+
 	c := &Client{
 		context:    ctx,
-		httpClient: defaultHttpClient,
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
 		attempts:   defaultAttemps,
 		delay:      defaultDelay,
 		strategy:   defaultStrategy,
 		policy:     defaultPolicy,
+		userAgent:  defaultUserAgent,
+		lifecycle:  lifecycle{done: make(chan struct{})},
+
+		methodPolicies:  map[string]Policy{},
+		methodAttempts:  map[string]uint32{},
+		hostOverrides:   map[string]Overrides{},
+		hostRateLimits:  map[string]*tokenBucket{},
+		endpointWeights: map[string]uint32{},
+		codecs:          defaultCodecs(),
+		callbacks:       make(semaphore, defaultCallbackWorkers),
 	}
 
 	for _, opt := range opts {
@@ -62,12 +158,57 @@ func New(ctx context.Context, opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	if c.customHttpClient && c.transportConfigured {
+		return nil, fmt.Errorf("transport options cannot be combined with a custom http client")
+	}
+
+	if c.proxyAuth != nil {
+		c.transport.Proxy = c.proxyAuth.wrap(c.transport.Proxy)
+	}
+
+	if c.healthCheck != nil && c.endpoints == nil {
+		return nil, fmt.Errorf("health checks require endpoints")
+	}
+
+	// Keep the host statistics the adaptive features are driven by, even if they aren't enabled.
+	if c.hostStats == nil && (c.adaptive != nil || c.outlierDetection != nil) {
+		c.hostStats = newHostStatsTracker()
+	}
+	if c.adaptive != nil {
+		c.adaptive.stats = c.hostStats
+	}
+
+	if c.outlierDetection != nil {
+		if c.endpoints == nil {
+			return nil, fmt.Errorf("outlier detection requires endpoints")
+		}
+		c.endpoints.outlierDetection = c.outlierDetection
+		c.endpoints.hostStats = c.hostStats
+	}
+
+	for endpoint, weight := range c.endpointWeights {
+		if err := c.SetEndpointWeight(endpoint, weight); err != nil {
+			return nil, fmt.Errorf("failed to set endpoint weight: %w", err)
+		}
+	}
+
+	// Start the background work last, once the client can no longer fail to be created.
+	if c.healthCheck != nil {
+		go c.runHealthChecks()
+	}
+	if c.audit != nil {
+		go c.audit.run(c.context, c.lifecycle.done)
+	}
+
 	return c, nil
 }
 
+// WithHttpClient sets the http.Client used to send attempts, replacing the client-managed one.
+// It can't be combined with the options configuring the managed transport.
 func WithHttpClient(httpClient *http.Client) ClientOption {
 	return func(c *Client) error {
 		c.httpClient = httpClient
+		c.customHttpClient = true
 
 		return nil
 	}
@@ -86,6 +227,9 @@ func WithAttempts(attempts uint32) ClientOption {
 
 func WithDelay(delay time.Duration) ClientOption {
 	return func(c *Client) error {
+		if delay < 0 || delay > backoffpolicy.MaxDelay {
+			return fmt.Errorf("invalid delay value '%s'", delay)
+		}
 		c.delay = delay
 
 		return nil
@@ -94,16 +238,33 @@ func WithDelay(delay time.Duration) ClientOption {
 
 func WithStrategy(strategy backoffpolicy.Strategy) ClientOption {
 	return func(c *Client) error {
-		if slices.Index([]backoffpolicy.Strategy{backoffpolicy.StrategyExponential, backoffpolicy.StrategyLinear}, strategy) == -1 {
-			return fmt.Errorf("invalid backoff strategy '%s'", strategy)
+		parsed, err := backoffpolicy.ParseStrategy(string(strategy))
+		if err != nil {
+			return err
+		}
+		c.strategy = parsed
+
+		return nil
+	}
+}
+
+// WithBackoffCurve replaces the delay and strategy with a custom backoff curve: the first retry waits
+// initial, and every following retry waits multiplier times longer than the previous one, e.g. 1.5.
+func WithBackoffCurve(initial time.Duration, multiplier float64) ClientOption {
+	return func(c *Client) error {
+		if initial < 0 || initial > backoffpolicy.MaxDelay {
+			return fmt.Errorf("invalid backoff initial interval value '%s'", initial)
+		}
+		if multiplier < 1 {
+			return fmt.Errorf("invalid backoff multiplier value '%g'", multiplier)
 		}
-		c.strategy = strategy
+		c.curve = &backoffpolicy.Curve{Initial: initial, Multiplier: multiplier}
 
 		return nil
 	}
 }
 
-func WithPolicy(policy func(resp *http.Response, err error) error) ClientOption {
+func WithPolicy(policy Policy) ClientOption {
 	return func(c *Client) error {
 		c.policy = policy
 
@@ -111,29 +272,106 @@ func WithPolicy(policy func(resp *http.Response, err error) error) ClientOption
 	}
 }
 
+// WithMaxConcurrentRequests limits the number of attempts in flight across the whole client.
+// Attempts beyond the limit wait for a free slot; a slot is held until the response body is closed.
+func WithMaxConcurrentRequests(n uint32) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("invalid max concurrent requests value '%d'", n)
+		}
+		c.concurrency = make(semaphore, n)
+
+		return nil
+	}
+}
+
+// WithMaxConcurrentRequestsPerHost limits the number of attempts in flight to each individual host.
+func WithMaxConcurrentRequestsPerHost(n uint32) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("invalid max concurrent requests per host value '%d'", n)
+		}
+		c.hostConcurrency = newHostSemaphores(n)
+
+		return nil
+	}
+}
+
+// WithRequestContext sets the context of a single request. The request is cancelled when either
+// ctx or the client context is done.
+func WithRequestContext(ctx context.Context) RequestOption {
+	return func(ro *requestOptions) error {
+		if ctx == nil {
+			return fmt.Errorf("nil request context")
+		}
+		ro.ctx = ctx
+
+		return nil
+	}
+}
+
+// requestContext returns the context of a request: the client context, or ctx merged with it when set,
+// bounded by the client timeout.
+// The returned cancel function must be called once the request and its response body are done with.
+func (c *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancel := func() {}
+	if ctx == nil {
+		ctx = c.context
+	} else {
+		var cancelCause context.CancelCauseFunc
+		ctx, cancelCause = context.WithCancelCause(ctx)
+		stop := context.AfterFunc(c.context, func() {
+			cancelCause(context.Cause(c.context))
+		})
+		cancel = func() {
+			stop()
+			cancelCause(context.Canceled)
+		}
+	}
+
+	// Bound the whole request, every attempt and backoff included, by the client timeout.
+	if c.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.timeout)
+		release := cancel
+		cancel = func() {
+			cancelTimeout()
+			release()
+		}
+	}
+
+	return ctx, cancel
+}
+
 // Post sends a POST request to the specified URL with the provided body and headers.
 // It uses the underlying retry mechanism to ensure that transient errors are retried
-// according to the configured policy.
-func (c *Client) Post(url string, body []byte, headers map[string]string) (*http.Response, error) {
-	return c.Do(url, http.MethodPost, body, headers)
+// according to the configured policy. The body is handled as by Do.
+func (c *Client) Post(url string, body any, headers map[string]string, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(url, http.MethodPost, body, headers, opts...)
 }
 
 // Get sends a GET request to the specified URL with the provided headers.
 // As GET requests do not typically have a body, an empty payload is used.
-func (c *Client) Get(url string, headers map[string]string) (*http.Response, error) {
-	return c.Do(url, http.MethodGet, nil, headers)
+func (c *Client) Get(url string, headers map[string]string, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(url, http.MethodGet, nil, headers, opts...)
 }
 
-// Do performs an HTTP request with the specified method, URL, body, and headers.
+// Do performs an HTTP request with the specified method, URL, body, headers, and request options.
 // It validates the URL, constructs the HTTP request with context support, and
 // manages retry attempts using the configured backoff strategy and policy.
 //
+// The body is sent as is when it's a []byte, a string or an io.Reader. Any other
+// value is marshalled with the codec registered for the Content-Type header, as JSON
+// by default, see WithCodec. A nil or empty body is sent as no body at all, and readers
+// that can be read at offsets, such as files, are sent with their length from the
+// current offset without being read into memory; they're left open for the caller.
+//
 // The function returns the HTTP response if successful, or an error if all
 // retry attempts fail.
-func (c *Client) Do(url, method string, body []byte, headers map[string]string) (*http.Response, error) {
+func (c *Client) Do(url, method string, body any, headers map[string]string, opts ...RequestOption) (*http.Response, error) {
 	// Validate URL format using go-playground/validator.
 	if err := validator.New().Var(url, "required,http_url"); err != nil {
-		return nil, fmt.Errorf("url validation failed: %w", err)
+		return nil, &ConfigError{Err: fmt.Errorf("url validation failed: %w", err)}
 	}
 
 	// Prepare HTTP headers from the provided map.
@@ -142,43 +380,577 @@ func (c *Client) Do(url, method string, body []byte, headers map[string]string)
 		header.Add(k, v)
 	}
 
-	// Create a new HTTP request with context to support cancellation and timeouts.
-	req, err := http.NewRequestWithContext(c.context, method, url, bytes.NewReader(body))
+	// Encode the body according to its type and the declared content type.
+	reader, err := c.encodeBody(body, header)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create http request: %w", err)
+		return nil, &ConfigError{Err: err}
+	}
+
+	// Create a new HTTP request; its context is set once the request options are known.
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("failed to create http request: %w", err)}
 	}
 	req.Header = header
+	if host := header.Get("Host"); host != "" {
+		// net/http ignores the Host header in favour of the request host.
+		req.Host = host
+		header.Del("Host")
+	}
+	if section, ok := reader.(*io.SectionReader); ok {
+		setSectionBody(req, section)
+	}
+
+	ro, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.send(req, ro)
+}
+
+// DoRequest performs an already built HTTP request with the retry logic of the client.
+// The request isn't modified: every attempt is sent as a clone of it, with the body rewound through
+// req.GetBody. Bodies without GetBody are read into memory up front so they can be replayed.
+// The request context is honoured along with the client context, unless WithRequestContext is used.
+func (c *Client) DoRequest(req *http.Request, opts ...RequestOption) (*http.Response, error) {
+	if req == nil || req.URL == nil {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid http request: missing url")}
+	}
+
+	ro, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if ro.ctx == nil {
+		ro.ctx = req.Context()
+	}
+
+	return c.send(req, ro)
+}
+
+// WithBodyProvider sets a function producing the request body, called again for every attempt
+// instead of replaying a buffered copy, so bodies streamed from pipes, encoders or database cursors
+// can be retried safely. It replaces any body given to Do or set on the request passed to DoRequest.
+// The body is sent with chunked transfer encoding as its length is unknown. The provider may also be
+// called to read the body for signing or for the exhausted hook, when those are configured.
+func WithBodyProvider(provider func() (io.ReadCloser, error)) RequestOption {
+	return func(ro *requestOptions) error {
+		if provider == nil {
+			return fmt.Errorf("nil body provider")
+		}
+		ro.bodyProvider = provider
+
+		return nil
+	}
+}
+
+// newRequestOptions applies opts to a new requestOptions.
+func newRequestOptions(opts []RequestOption) (*requestOptions, error) {
+	ro := &requestOptions{}
+	for _, opt := range opts {
+		if err := opt(ro); err != nil {
+			return nil, &ConfigError{Err: fmt.Errorf("failed to set optional request field: %w", err)}
+		}
+	}
+
+	return ro, nil
+}
+
+// send executes req and keeps the client statistics up to date.
+func (c *Client) send(req *http.Request, ro *requestOptions) (*http.Response, error) {
+	if c.lifecycle.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	if c.quota != nil {
+		if err := c.quota.take(); err != nil {
+			return nil, err
+		}
+	}
+
+	c.stats.requests.Add(1)
+	c.stats.inFlight.Add(1)
+	defer c.stats.inFlight.Add(-1)
+
+	resp, err := c.execute(req, ro)
+	if err != nil {
+		c.stats.failures.Add(1)
+	} else {
+		c.stats.successes.Add(1)
+	}
+
+	return resp, err
+}
 
-	var resp = &http.Response{}
+// execute sends clones of req until the policy accepts a response or the attempts are exhausted.
+func (c *Client) execute(original *http.Request, ro *requestOptions) (*http.Response, error) {
+	ctx, cancel := c.requestContext(ro.ctx)
+
+	// Count the request as retrying from its first retry until it completes.
+	var retrying bool
+	// A request is reauthenticated at most once, however many attempts are rejected with 401.
+	var reauthenticated bool
+	defer func() {
+		if retrying {
+			c.stats.retrying.Add(-1)
+		}
+	}()
+
+	// Collect the attempt metadata in the request context, where it can be found from the response.
+	info := &RetryInfo{}
+	ctx = withRetryInfo(ctx, info)
+
+	// Work on a clone so that the caller's request is left untouched.
+	req := original.Clone(ctx)
+	if ro.bodyProvider != nil {
+		req.Body, req.GetBody, req.ContentLength = nil, ro.bodyProvider, -1
+	}
+	if err := makeReplayable(req); err != nil {
+		cancel()
+		return nil, &ConfigError{Err: err}
+	}
+	applyTrailers(req, ro.trailer)
+	c.applyExpectContinue(req)
+
+	if ro.accept != "" {
+		req.Header.Set("Accept", ro.accept)
+	}
+	c.applyQueryParams(req, ro.queryParams)
+
+	// Keep track of the headers set by the caller, as later steps must not override them.
+	callerHeader := req.Header.Clone()
+
+	// Resolve the Host header once, as routing attempts to endpoints resets it.
+	host := c.hostHeaderFor(req, ro)
+
+	// Credentials set on the request itself take precedence over the client-level authentication.
+	explicitAuth := callerHeader.Get("Authorization") != ""
+	if ro.basicAuth != nil {
+		req.SetBasicAuth(ro.basicAuth.username, ro.basicAuth.password)
+		explicitAuth = true
+	}
+
+	// Assign the ID shared by every attempt of this request, and identify the client.
+	requestID := c.assignRequestID(req)
+	c.applyUserAgent(req)
+
+	var (
+		resp      = &http.Response{}
+		err       error
+		notBefore time.Time
+		lastResp  *http.Response
+		lastEnd   time.Time
+		errs      []error
+		freshConn bool
+	)
+
+	// Hash the payload once up front, as signers need it for every attempt.
+	var payloadHash []byte
+	if c.signer != nil {
+		body, err := readRequestBody(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		sum := sha256.Sum256(body)
+		payloadHash = sum[:]
+	}
+
+	// Resolve the retry settings that apply to this request.
+	settings := c.settingsFor(req, ro)
+	curve, err := settings.backoffCurve()
+	if err != nil {
+		cancel()
+		return nil, withRequestID(requestID, err)
+	}
+
+	// Spread requests to the configured endpoints, starting from the next one in rotation.
+	useEndpoints := c.endpoints != nil && c.endpoints.contains(req.URL)
+	var endpointStart uint64
+	if useEndpoints {
+		endpointStart = c.endpoints.start()
+	}
 
 	select {
-	case <-c.context.Done():
-		return nil, fmt.Errorf("context closed: %w", c.context.Err())
+	case <-ctx.Done():
+		retryErr := newRetryError(ctx, 0, fmt.Errorf("context closed: %w", ctx.Err()))
+		cancel()
+		return nil, withRequestID(requestID, retryErr)
 	default:
 		// Execute the HTTP request with retry logic using the configured backoff policy.
-		err = backoffpolicy.BackoffPolicy(c.strategy, c.attempts, c.delay, func(attempt uint32) error {
+		err = backoffpolicy.BackoffPolicyCurve(ctx, curve, settings.attempts, func(a backoffpolicy.Attempt) (err error) {
+			attempt := a.Number
+
+			// Keep the error of every failed attempt for the exhausted hook.
+			defer func() {
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}()
+
 			// Ensure that the context is still active before each retry attempt.
-			if c.context.Err() != nil {
-				err := fmt.Errorf("retryable http call context closed: %w", c.context.Err())
+			if ctx.Err() != nil {
+				err := fmt.Errorf("retryable http call context closed: %w", ctx.Err())
 				return err
 			}
 
-			// For retries beyond the first attempt, reset the request body.
+			// Honour a rate limit reset announced by the previous response before sending again.
+			if wait := time.Until(notBefore); wait > 0 {
+				if err := sleepContext(ctx, wait); err != nil {
+					return fmt.Errorf("retryable http call context closed: %w", err)
+				}
+			}
+
+			// For retries beyond the first attempt, account for the backoff.
+			previousEnd := lastEnd
 			if attempt > 0 {
-				req.Body = io.NopCloser(bytes.NewReader(body))
+				c.stats.retries.Add(1)
+				info.TotalBackoff += time.Since(lastEnd)
+			}
+
+			// Rewind the request body for retries, or obtain a fresh one from the body provider for every attempt.
+			if attempt > 0 || ro.bodyProvider != nil {
+				if err := rewindBody(req); err != nil {
+					return backoffpolicy.Permanent(&ConfigError{Err: err})
+				}
+			}
+
+			// Send each attempt to the next available endpoint.
+			var target *endpoint
+			if useEndpoints {
+				target = c.endpoints.pick(endpointStart, attempt)
+				routeToEndpoint(req, target)
+			}
+			if host != "" {
+				req.Host = host
+			}
+
+			// Skip a retry that can't complete before the deadline, given the latency observed so far.
+			if attempt > 0 && c.latency != nil {
+				if deadline, ok := ctx.Deadline(); ok {
+					if expected, ok := c.latency.expected(req.URL.Host); ok && time.Until(deadline) < expected {
+						return backoffpolicy.Permanent(fmt.Errorf("skipped attempt expected to take %s: %w: %w", expected.Round(time.Millisecond), backoffpolicy.ErrWouldExceedDeadline, errors.Join(errs...)))
+					}
+				}
+			}
+
+			// Authenticate the attempt with the client-level credentials, unless the request carries its own.
+			if !explicitAuth && c.auth != nil {
+				if err := c.auth.Authenticate(req); err != nil {
+					return fmt.Errorf("failed to authenticate request: %w", err)
+				}
+			}
+
+			// Propagate values and the remaining deadline from the request context as headers.
+			c.applyContextHeaders(req, callerHeader)
+			c.applyDeadlineHeader(req)
+
+			// Let the caller regenerate time-sensitive values for the attempt.
+			if c.attemptMutator != nil {
+				if err := c.attemptMutator(attempt, req); err != nil {
+					return fmt.Errorf("failed to mutate request: %w", err)
+				}
+			}
+
+			// Sign the attempt last, once every header it may cover has been set.
+			if c.signer != nil {
+				if err := c.signer.Sign(req, payloadHash); err != nil {
+					return fmt.Errorf("failed to sign request: %w", err)
+				}
+			}
+
+			// Wait for the host's rate limit, then for a free slot in the configured concurrency limits.
+			if err := c.waitHostRateLimit(ctx, req); err != nil {
+				return fmt.Errorf("failed to wait for host rate limit: %w", err)
+			}
+			if c.limiter != nil {
+				if err := c.limiter.Wait(ctx, c.limiterKey(req)); err != nil {
+					return fmt.Errorf("failed to wait for limiter: %w", err)
+				}
+			}
+
+			release, err := c.acquireSlots(ctx, req.URL.Host, ro.slots)
+			if errors.Is(err, ErrConcurrencyLimited) {
+				return backoffpolicy.Permanent(fmt.Errorf("failed to acquire concurrency slot: %w", err))
+			}
+			if err != nil {
+				return fmt.Errorf("failed to acquire concurrency slot: %w", err)
 			}
 
 			// Perform the HTTP request.
-			resp, err = c.httpClient.Do(req)
+			c.emit(EventAttemptStart, req, requestID, attempt, nil, nil)
+			sentAt := time.Now()
+			send := req
+			var tracer *attemptTracer
+			if c.attemptTracing {
+				tracer = &attemptTracer{start: sentAt}
+				send = req.WithContext(tracer.trace(req.Context()))
+			}
+			traced, conn := c.traceConn(send.Context())
+			send = send.WithContext(traced)
+			var deadline *bodyDeadline
+			if c.bodyReadTimeout > 0 {
+				var attemptCtx context.Context
+				attemptCtx, deadline = newBodyDeadline(send.Context(), c.bodyReadTimeout)
+				send = send.WithContext(attemptCtx)
+			}
+			sender := c.httpClient
+			if freshConn {
+				sender = c.freshConnectionClient()
+			}
+			resp, err = sender.Do(send)
+			if err == nil && resp.StatusCode == http.StatusUnauthorized && !explicitAuth {
+				resp, err = c.answerChallenge(sender, req, send, resp)
+			}
+			if err == nil && resp.StatusCode == http.StatusUnauthorized && !explicitAuth && !reauthenticated && c.auth != nil {
+				reauthenticated = true
+				resp, err = c.reauthenticate(sender, req, send, resp)
+			}
+			if deadline != nil {
+				deadline.watch(resp)
+			}
+			if c.bufferResponse && resp != nil {
+				if _, readErr := bufferResponseBody(resp); readErr != nil {
+					resp, err = nil, readErr
+				}
+			}
+			var transformErr error
+			if err == nil && (len(c.transforms) > 0 || c.routeTransforms != nil) {
+				transformErr = c.transformResponse(req, resp)
+			}
+			freshConn = c.freshConnections != nil && ClassifyError(err) == ErrorClassConnectionReset
+			attemptCongested := c.aimdLimits != nil && congested(resp, err)
+			if c.proxyAuth != nil && proxyAuthRequired(resp, err) {
+				c.proxyAuth.invalidate()
+			}
+			info.record(resp)
+			lastEnd = time.Now()
+			c.emit(EventAttemptResponse, req, requestID, attempt, resp, err)
+
+			// Use the custom policy to determine if a retry should occur, unless a retry rule decides otherwise.
+			// A failed response transform fails the attempt in place of the policy.
+			if transformErr != nil {
+				err = transformErr
+			} else {
+				policyErr := c.applyStatusCodes(resp, settings.policy(resp, err))
+				if len(c.rules) > 0 {
+					var delay time.Duration
+					if policyErr, delay = c.applyRules(req, resp, err, policyErr); delay > 0 {
+						notBefore = time.Now().Add(delay)
+					}
+				}
+				err = policyErr
+			}
 
-			// Use the custom policy to determine if a retry should occur.
-			return c.policy(resp, err)
+			// Reject accepted responses that don't have the expected content type.
+			if err == nil && resp != nil && len(ro.contentTypes) > 0 {
+				err = checkContentType(resp, ro.contentTypes)
+			}
+
+			// Check accepted responses against the digests of their body.
+			if err == nil && resp != nil && c.integrityCheck {
+				err = checkIntegrity(req, resp)
+			}
+
+			// Validate the content of accepted responses.
+			if err == nil && resp != nil && c.responseValidator != nil {
+				err = c.validateResponse(resp)
+			}
+
+			// Verify the signature of accepted responses.
+			if err == nil && resp != nil && c.verifier != nil {
+				err = c.verifyResponse(resp)
+			}
+
+			// Record the timing and outcome of the attempt.
+			timing := AttemptTiming{}
+			if tracer != nil {
+				timing = tracer.phases()
+			}
+			timing.Start, timing.End, timing.Err = sentAt, lastEnd, err
+			timing.ConnReused, timing.ConnIdleTime = conn.reused.Load(), time.Duration(conn.idle.Load())
+			if attempt > 0 {
+				timing.Delay = sentAt.Sub(previousEnd)
+			}
+			if resp != nil {
+				timing.StatusCode = resp.StatusCode
+			}
+			info.Timings = append(info.Timings, timing)
+			c.logAttempt(ctx, req, requestID, attempt, resp, timing)
+			c.auditAttempt(ctx, req, requestID, attempt, resp, timing)
+
+			// Feed the outcome to the trackers adjusting later attempts.
+			if c.hostStats != nil {
+				c.hostStats.record(req.URL.Host, attempt > 0, err == nil, lastEnd.Sub(sentAt), lastEnd)
+			}
+			if c.latency != nil {
+				c.latency.record(req.URL.Host, lastEnd.Sub(sentAt))
+			}
+			if c.aimdLimits != nil {
+				c.aimdLimits.get(req.URL.Host).record(lastEnd.Sub(sentAt), attemptCongested, lastEnd)
+			}
+			if target != nil && c.outlierDetection != nil {
+				c.endpoints.record(target, err == nil)
+			}
+			if resp != nil {
+				c.hostQuotas.record(req.URL.Host, resp, lastEnd)
+			}
+
+			if err != nil {
+				// Delay the next attempt until the rate limit resets, or give up if that's too far away.
+				if c.rateLimitMaxWait > 0 && resp != nil {
+					if wait, ok := rateLimitWait(resp, time.Now()); ok {
+						if wait > c.rateLimitMaxWait {
+							err = backoffpolicy.Permanent(fmt.Errorf("rate limit resets in %s, beyond max wait: %w", wait, err))
+						}
+						notBefore = time.Now().Add(wait)
+					}
+				}
+
+				// Attach the start of the rejected response body to the error, if enabled.
+				if c.errorBodyLimit > 0 && resp != nil {
+					err = captureResponse(resp, err, c.errorBodyLimit, c.redactor)
+				}
+
+				// Close the rejected response so its connection and slots are freed before retrying,
+				// keeping a copy if it may have to be returned once retries give up.
+				if resp != nil {
+					if c.returnLastResponse {
+						bufferBody(resp)
+						lastResp = resp
+					} else {
+						resp.Body.Close()
+					}
+				}
+				release()
+
+				if !isPermanent(err) && attempt+1 < settings.attempts {
+					if !retrying {
+						retrying = true
+						c.stats.retrying.Add(1)
+					}
+
+					// Fail fast rather than retrying while the process is under pressure, or joining a retry storm.
+					if len(c.loadProbes) > 0 && c.underPressure() {
+						c.stats.retriesShed.Add(1)
+						return backoffpolicy.Permanent(fmt.Errorf("%w: %w", ErrRetryShed, err))
+					}
+					if c.retryDamping != nil && c.retryDamping.drop(c.stats.retrying.Load(), c.stats.inFlight.Load()) {
+						c.stats.retriesDropped.Add(1)
+						return backoffpolicy.Permanent(fmt.Errorf("%w: %w", ErrRetryDropped, err))
+					}
+
+					c.emit(EventRetryScheduled, req, requestID, attempt, resp, err)
+				}
+
+				return err
+			}
+
+			if resp != nil && !c.bufferResponse {
+				resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+			} else {
+				release()
+			}
+
+			return nil
 		})
 
+		// Requests that can no longer be built fail as is.
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			cancel()
+			return nil, withRequestID(requestID, configErr)
+		}
+
 		if err != nil {
-			return nil, fmt.Errorf("backoff policy expired: %w", err)
+			// Classify the failure before releasing the request context, which would mark it as cancelled.
+			retryErr := newRetryError(ctx, info.Attempts, fmt.Errorf("backoff policy expired: %w", err))
+			cancel()
+			c.reportTiming(info, requestID, req.Method, req.URL.String(), retryErr)
+			c.logRequest(ctx, req, requestID, info, lastResp, retryErr)
+			c.emit(EventExhausted, req, requestID, max(info.Attempts, 1)-1, nil, err)
+			if c.onExhausted != nil {
+				if len(errs) == 0 {
+					errs = []error{err}
+				}
+				body, _ := readRequestBody(req)
+				c.onExhausted(RequestSnapshot{
+					RequestID: requestID,
+					Method:    req.Method,
+					URL:       req.URL.String(),
+					Header:    callerHeader,
+					Body:      body,
+				}, errs)
+			}
+			return lastResp, withRequestID(requestID, retryErr)
+		}
+
+		c.reportTiming(info, requestID, req.Method, req.URL.String(), nil)
+		c.logRequest(ctx, req, requestID, info, resp, nil)
+
+		// Keep the request context alive until the caller is done with the response body, unless it's
+		// already buffered, in which case it's handed over from the start.
+		if resp != nil {
+			if b, ok := resp.Body.(*BufferedBody); ok && c.bufferResponse {
+				b.Seek(0, io.SeekStart)
+				cancel()
+			} else {
+				resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: cancel}
+			}
+			if ro.onTrailers != nil {
+				resp.Body = &trailerNotifier{ReadCloser: resp.Body, resp: resp, onTrailers: ro.onTrailers}
+			}
+			if ro.tee != nil {
+				resp.Body = &teeBody{ReadCloser: resp.Body, w: ro.tee}
+			}
+		} else {
+			cancel()
 		}
 
 		return resp, err
 	}
 }
+
+// makeReplayable ensures req's body can be rewound for retries, reading it into memory when
+// it can't be obtained again through GetBody.
+func makeReplayable(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Send empty bodies as no body at all, which strict servers expect e.g. on GET requests.
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return nil
+}
+
+// rewindBody replaces the consumed body of req with a fresh copy obtained through GetBody.
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package retryablehttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RetryInfo describes the attempts made to obtain a response.
+type RetryInfo struct {
+	// Attempts is the number of attempts sent, including the first one.
+	Attempts uint32
+	// TotalBackoff is the time spent waiting between attempts.
+	TotalBackoff time.Duration
+	// Statuses holds the status code of each attempt, or 0 for attempts that failed without a response.
+	Statuses []int
+	// Timings holds the timing and outcome of each attempt.
+	Timings []AttemptTiming
+}
+
+type retryInfoKey struct{}
+
+// RetryInfoFromResponse returns the retry metadata of a response returned by the client.
+func RetryInfoFromResponse(resp *http.Response) (*RetryInfo, bool) {
+	if resp == nil || resp.Request == nil {
+		return nil, false
+	}
+
+	info, ok := resp.Request.Context().Value(retryInfoKey{}).(*RetryInfo)
+
+	return info, ok
+}
+
+// withRetryInfo returns a context carrying info, so it can be retrieved from the response.
+func withRetryInfo(ctx context.Context, info *RetryInfo) context.Context {
+	return context.WithValue(ctx, retryInfoKey{}, info)
+}
+
+// record adds the outcome of an attempt to the metadata.
+func (i *RetryInfo) record(resp *http.Response) {
+	i.Attempts++
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	i.Statuses = append(i.Statuses, status)
+}
@@ -0,0 +1,298 @@
+package retryablehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// RuleAction is what a RetryRule does with a matching attempt outcome.
+type RuleAction string
+
+const (
+	// RuleRetry retries the request.
+	RuleRetry RuleAction = "retry"
+	// RuleStop fails the request without any further attempt.
+	RuleStop RuleAction = "stop"
+	// RuleAccept returns the response to the caller as a success. It only applies to responses.
+	RuleAccept RuleAction = "accept"
+)
+
+// ErrorClass identifies a kind of transport error that a RetryRule can match.
+type ErrorClass string
+
+const (
+	// ErrorClassTimeout matches attempts that timed out.
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassNetwork matches every attempt that failed without a response, except when the
+	// request context was cancelled.
+	ErrorClassNetwork ErrorClass = "network"
+)
+
+// RetryRule decides the outcome of the attempts it matches. Every criterion that is set must match;
+// a list matches when any of its entries does, and a response matches the outcome criteria when its
+// status is listed in Statuses, a transport error when its class is listed in Errors. A rule without
+// Statuses nor Errors matches the attempts rejected by the policy.
+//
+// Rules are plain data so they can be loaded from configuration, see ParseRetryRules.
+type RetryRule struct {
+	// Statuses lists status codes ("503"), ranges ("500-504") or classes ("5xx").
+	Statuses []string `json:"statuses,omitempty"`
+	// Errors lists transport error classes.
+	Errors []ErrorClass `json:"errors,omitempty"`
+	// Methods lists HTTP methods.
+	Methods []string `json:"methods,omitempty"`
+	// Hosts lists request hosts, either exact ("api.example.com", "api.example.com:8443") or by
+	// domain suffix ("*.example.com").
+	Hosts []string `json:"hosts,omitempty"`
+	// Action is what happens to matching attempts.
+	Action RuleAction `json:"action"`
+	// Delay is the minimum wait before retrying a matching attempt, as a duration like "2s",
+	// overriding a shorter backoff. Only valid with RuleRetry.
+	Delay string `json:"delay,omitempty"`
+}
+
+// statusRange is an inclusive range of status codes.
+type statusRange struct {
+	min, max int
+}
+
+// compiledRule is a RetryRule validated and parsed for matching.
+type compiledRule struct {
+	statuses []statusRange
+	errors   []ErrorClass
+	methods  []string
+	hosts    []string
+	action   RuleAction
+	delay    time.Duration
+}
+
+// ParseRetryRules decodes a JSON array of rules, e.g.
+//
+//	[{"statuses": ["429", "503"], "action": "retry", "delay": "5s"},
+//	 {"statuses": ["4xx"], "action": "stop"}]
+//
+// and validates them.
+func ParseRetryRules(data []byte) ([]RetryRule, error) {
+	var rules []RetryRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode retry rules: %w", err)
+	}
+
+	if _, err := compileRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// WithRetryRules evaluates rules in order after every attempt: the first rule matching the outcome
+// decides whether to retry, stop or accept it, and outcomes matching no rule are left to the policy.
+func WithRetryRules(rules ...RetryRule) ClientOption {
+	return func(c *Client) error {
+		compiled, err := compileRules(rules)
+		if err != nil {
+			return err
+		}
+		c.rules = compiled
+
+		return nil
+	}
+}
+
+func compileRules(rules []RetryRule) ([]compiledRule, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no retry rules specified")
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		cr, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry rule %d: %w", i, err)
+		}
+		compiled = append(compiled, cr)
+	}
+
+	return compiled, nil
+}
+
+func compileRule(rule RetryRule) (compiledRule, error) {
+	cr := compiledRule{action: rule.Action, errors: rule.Errors}
+
+	switch rule.Action {
+	case RuleRetry, RuleStop:
+	case RuleAccept:
+		if len(rule.Errors) > 0 {
+			return cr, fmt.Errorf("errors can't be accepted")
+		}
+	default:
+		return cr, fmt.Errorf("invalid action '%s'", rule.Action)
+	}
+
+	for _, s := range rule.Statuses {
+		r, err := parseStatusRange(s)
+		if err != nil {
+			return cr, err
+		}
+		cr.statuses = append(cr.statuses, r)
+	}
+
+	for _, class := range rule.Errors {
+		if !slices.Contains([]ErrorClass{ErrorClassTimeout, ErrorClassNetwork, ErrorClassDNS, ErrorClassDNSNotFound, ErrorClassTLSCertificate, ErrorClassConnectionReset}, class) {
+			return cr, fmt.Errorf("invalid error class '%s'", class)
+		}
+	}
+
+	for _, method := range rule.Methods {
+		cr.methods = append(cr.methods, strings.ToUpper(method))
+	}
+
+	for _, host := range rule.Hosts {
+		if host == "" {
+			return cr, fmt.Errorf("empty host")
+		}
+		cr.hosts = append(cr.hosts, strings.ToLower(host))
+	}
+
+	if rule.Delay != "" {
+		if rule.Action != RuleRetry {
+			return cr, fmt.Errorf("delay requires the '%s' action", RuleRetry)
+		}
+		delay, err := time.ParseDuration(rule.Delay)
+		if err != nil || delay < 0 {
+			return cr, fmt.Errorf("invalid delay value '%s'", rule.Delay)
+		}
+		cr.delay = delay
+	}
+
+	return cr, nil
+}
+
+// parseStatusRange parses a status code, a range of status codes or a status class.
+func parseStatusRange(s string) (statusRange, error) {
+	invalid := fmt.Errorf("invalid status '%s'", s)
+
+	if len(s) == 3 && strings.HasSuffix(strings.ToLower(s), "xx") {
+		class, err := strconv.Atoi(s[:1])
+		if err != nil || class < 1 || class > 5 {
+			return statusRange{}, invalid
+		}
+
+		return statusRange{min: class * 100, max: class*100 + 99}, nil
+	}
+
+	first, last, isRange := strings.Cut(s, "-")
+	if !isRange {
+		last = first
+	}
+	lo, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return statusRange{}, invalid
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil || lo < 100 || hi > 599 || lo > hi {
+		return statusRange{}, invalid
+	}
+
+	return statusRange{min: lo, max: hi}, nil
+}
+
+// matches reports whether the rule applies to the outcome of an attempt of req, given whether the
+// policy rejected it.
+func (r *compiledRule) matches(req *http.Request, resp *http.Response, err error, rejected bool) bool {
+	if len(r.methods) > 0 && !slices.Contains(r.methods, req.Method) {
+		return false
+	}
+	if len(r.hosts) > 0 && !slices.ContainsFunc(r.hosts, func(host string) bool { return matchHost(host, req.URL) }) {
+		return false
+	}
+
+	if err != nil {
+		if r.action == RuleAccept {
+			return false
+		}
+		if len(r.errors) == 0 {
+			return len(r.statuses) == 0 && rejected
+		}
+
+		return slices.ContainsFunc(r.errors, func(class ErrorClass) bool { return matchErrorClass(class, err) })
+	}
+
+	if resp == nil {
+		return false
+	}
+	if len(r.statuses) == 0 {
+		return len(r.errors) == 0 && rejected
+	}
+
+	return slices.ContainsFunc(r.statuses, func(s statusRange) bool {
+		return resp.StatusCode >= s.min && resp.StatusCode <= s.max
+	})
+}
+
+// matchHost reports whether a rule host matches the host of u.
+func matchHost(host string, u *url.URL) bool {
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		return strings.HasSuffix(strings.ToLower(u.Hostname()), "."+suffix)
+	}
+
+	return host == strings.ToLower(u.Host) || host == strings.ToLower(u.Hostname())
+}
+
+// matchErrorClass reports whether err belongs to class.
+func matchErrorClass(class ErrorClass, err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	switch class {
+	case ErrorClassTimeout:
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	case ErrorClassNetwork:
+		return true
+	case ErrorClassDNS, ErrorClassDNSNotFound, ErrorClassTLSCertificate, ErrorClassConnectionReset:
+		return ClassifyError(err) == class
+	default:
+		return false
+	}
+}
+
+// applyRules returns the outcome decided by the first rule matching the attempt, and the minimum
+// delay before retrying. policyErr is the outcome decided by the policy, returned when no rule matches.
+func (c *Client) applyRules(req *http.Request, resp *http.Response, err, policyErr error) (outcome error, delay time.Duration) {
+	for i := range c.rules {
+		rule := &c.rules[i]
+		if !rule.matches(req, resp, err, policyErr != nil) {
+			continue
+		}
+
+		switch rule.action {
+		case RuleAccept:
+			return nil, 0
+		case RuleStop:
+			if err != nil {
+				return backoffpolicy.Permanent(fmt.Errorf("error matched stop rule: %w", err)), 0
+			}
+			return backoffpolicy.Permanent(fmt.Errorf("HTTP response status code (%d) matched stop rule", resp.StatusCode)), 0
+		default:
+			if err != nil {
+				return fmt.Errorf("propagating error: %w", err), rule.delay
+			}
+			return fmt.Errorf("HTTP response status code (%d) matched retry rule", resp.StatusCode), rule.delay
+		}
+	}
+
+	return policyErr, 0
+}
@@ -0,0 +1,135 @@
+// Package schemavalidator validates response payloads against JSON Schemas selected by route, as a
+// response validator for retryablehttp.WithResponseValidator, so malformed upstream payloads are caught
+// at the client boundary.
+package schemavalidator // import "github.com/condrove10/retryablehttp/schemavalidator"
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// ValidationError reports a response payload that doesn't conform to the schema of its route.
+type ValidationError struct {
+	// Pattern is the route pattern the request matched.
+	Pattern string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("response for route '%s' doesn't match its schema: %s", e.Pattern, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validator holds a JSON Schema per route pattern. Responses to requests matching no route aren't
+// validated.
+type Validator struct {
+	// Retry makes invalid responses retried, rather than failing the request right away.
+	Retry bool
+
+	mu      sync.RWMutex
+	mux     *http.ServeMux
+	schemas map[string]*jsonschema.Schema
+}
+
+// New returns a Validator without any route.
+func New() *Validator {
+	return &Validator{mux: http.NewServeMux(), schemas: map[string]*jsonschema.Schema{}}
+}
+
+// Add compiles schema, a JSON Schema document, for the responses to the requests matching pattern.
+// Patterns follow the syntax of http.ServeMux, e.g. "GET api.example.com/users/{id}" or "/orders/",
+// and the most specific pattern matching a request applies.
+func (v *Validator) Add(pattern string, schema []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("failed to decode schema for route '%s': %w", pattern, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.schemas[pattern]; ok {
+		return fmt.Errorf("duplicate route '%s'", pattern)
+	}
+
+	url := fmt.Sprintf("mem:///routes/%d.json", len(v.schemas))
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, doc); err != nil {
+		return fmt.Errorf("failed to load schema for route '%s': %w", pattern, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("failed to compile schema for route '%s': %w", pattern, err)
+	}
+
+	// The mux only matches routes; its handlers are never called.
+	if err := registerRoute(v.mux, pattern); err != nil {
+		return err
+	}
+	v.schemas[pattern] = compiled
+
+	return nil
+}
+
+// registerRoute adds pattern to mux, reporting the panics of invalid or conflicting patterns as errors.
+func registerRoute(mux *http.ServeMux, pattern string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid route '%s': %v", pattern, r)
+		}
+	}()
+	mux.Handle(pattern, http.NotFoundHandler())
+
+	return nil
+}
+
+// Validate validates body against the schema of the route of resp, with the signature expected by
+// retryablehttp.WithResponseValidator. Invalid payloads are reported as a *ValidationError, wrapped
+// with backoffpolicy.Permanent unless Retry is set.
+func (v *Validator) Validate(resp *http.Response, body []byte) error {
+	if resp.Request == nil {
+		return nil
+	}
+
+	// Match against the target host, which client requests leave out of req.Host.
+	req := resp.Request.Clone(resp.Request.Context())
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	v.mu.RLock()
+	_, pattern := v.mux.Handler(req)
+	schema, ok := v.schemas[pattern]
+	v.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	err := v.validate(schema, body)
+	if err == nil {
+		return nil
+	}
+	err = &ValidationError{Pattern: pattern, Err: err}
+	if !v.Retry {
+		return backoffpolicy.Permanent(err)
+	}
+
+	return err
+}
+
+func (v *Validator) validate(schema *jsonschema.Schema, body []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+
+	return schema.Validate(doc)
+}
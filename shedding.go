@@ -0,0 +1,68 @@
+package retryablehttp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+)
+
+// ErrRetryShed reports a request failed without retrying because the process was under pressure, see
+// WithRetryShedding.
+var ErrRetryShed = errors.New("retry shed under load")
+
+// LoadProbe reports whether the process is under too much pressure to afford retries. It's called on
+// every retry, so it must be cheap.
+type LoadProbe func() bool
+
+// WithRetryShedding sheds retries while any of probes reports the process under pressure, so the retry
+// layer degrades gracefully rather than adding load to a struggling process: requests whose attempt
+// failed then fail right away with ErrRetryShed, while first attempts are still sent. See
+// GoroutineProbe and HeapProbe for probes on the Go runtime.
+func WithRetryShedding(probes ...LoadProbe) ClientOption {
+	return func(c *Client) error {
+		if len(probes) == 0 {
+			return fmt.Errorf("no load probe specified")
+		}
+		for _, probe := range probes {
+			if probe == nil {
+				return fmt.Errorf("nil load probe")
+			}
+		}
+		c.loadProbes = probes
+
+		return nil
+	}
+}
+
+// GoroutineProbe returns a LoadProbe reporting pressure while the process runs more than max goroutines.
+func GoroutineProbe(max int) LoadProbe {
+	return func() bool {
+		return runtime.NumGoroutine() > max
+	}
+}
+
+// heapMetric is the runtime metric holding the memory occupied by live and not yet collected heap objects.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// HeapProbe returns a LoadProbe reporting pressure while the heap of the process holds more than max
+// bytes of objects, live or not yet collected.
+func HeapProbe(max uint64) LoadProbe {
+	return func() bool {
+		sample := []metrics.Sample{{Name: heapMetric}}
+		metrics.Read(sample)
+
+		return sample[0].Value.Kind() == metrics.KindUint64 && sample[0].Value.Uint64() > max
+	}
+}
+
+// underPressure reports whether any load probe reports the process under pressure.
+func (c *Client) underPressure() bool {
+	for _, probe := range c.loadProbes {
+		if probe() {
+			return true
+		}
+	}
+
+	return false
+}
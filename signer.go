@@ -0,0 +1,126 @@
+package retryablehttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Signer signs an attempt right before it's sent. payloadHash is the SHA-256 hash of the request body.
+// Signers run after every other header has been set, and again for each retry.
+type Signer interface {
+	Sign(req *http.Request, payloadHash []byte) error
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(req *http.Request, payloadHash []byte) error
+
+func (f SignerFunc) Sign(req *http.Request, payloadHash []byte) error {
+	return f(req, payloadHash)
+}
+
+// WithRequestSigner signs every attempt with signer.
+func WithRequestSigner(signer Signer) ClientOption {
+	return func(c *Client) error {
+		if signer == nil {
+			return fmt.Errorf("nil signer")
+		}
+		c.signer = signer
+
+		return nil
+	}
+}
+
+// HMACCanonicalization selects the message an HMACSigner signs.
+type HMACCanonicalization int
+
+const (
+	// HMACCanonicalBody signs the raw request body, prefixed with "<timestamp>." when a timestamp header is configured.
+	HMACCanonicalBody HMACCanonicalization = iota
+	// HMACCanonicalRequest signs the method, request URI, timestamp and hex-encoded body hash, separated by newlines.
+	HMACCanonicalRequest
+)
+
+// HMACSigner signs requests with HMAC-SHA256, as commonly required by webhook-style APIs.
+// The hex-encoded signature is written to Header, preceded by Prefix (e.g. "sha256=").
+// When TimestampHeader is set, the current unix time is written to it and covered by the signature.
+type HMACSigner struct {
+	Key              []byte
+	Header           string
+	Prefix           string
+	TimestampHeader  string
+	Canonicalization HMACCanonicalization
+}
+
+func (s *HMACSigner) Sign(req *http.Request, payloadHash []byte) error {
+	if len(s.Key) == 0 {
+		return fmt.Errorf("empty hmac key")
+	}
+	if s.Header == "" {
+		return fmt.Errorf("empty hmac signature header")
+	}
+
+	var timestamp string
+	if s.TimestampHeader != "" {
+		timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(s.TimestampHeader, timestamp)
+	}
+
+	var message []byte
+	switch s.Canonicalization {
+	case HMACCanonicalBody:
+		body, err := readRequestBody(req)
+		if err != nil {
+			return err
+		}
+
+		if timestamp != "" {
+			message = append([]byte(timestamp+"."), body...)
+		} else {
+			message = body
+		}
+	case HMACCanonicalRequest:
+		message = []byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + hex.EncodeToString(payloadHash))
+	default:
+		return fmt.Errorf("invalid hmac canonicalization '%d'", s.Canonicalization)
+	}
+
+	req.Header.Set(s.Header, s.Prefix+hex.EncodeToString(hmacSHA256(s.Key, message)))
+
+	return nil
+}
+
+// readRequestBody returns a copy of the request body without consuming it, using GetBody.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		if req.Body == nil || req.Body == http.NoBody {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("request body cannot be read without consuming it")
+	}
+
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request body: %w", err)
+	}
+	defer rc.Close()
+
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return body, nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
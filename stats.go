@@ -0,0 +1,95 @@
+package retryablehttp
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ClientStats is a snapshot of the client's operational counters.
+type ClientStats struct {
+	// Requests is the number of logical requests started, regardless of how many attempts they took.
+	Requests uint64
+	// Retries is the number of attempts sent after the first one of a request.
+	Retries uint64
+	// Successes is the number of requests that returned a response.
+	Successes uint64
+	// Failures is the number of requests that returned an error.
+	Failures uint64
+	// InFlight is the number of requests currently in progress, and Retrying the number of those that
+	// are retrying after a failed attempt.
+	InFlight int64
+	Retrying int64
+	// OpenCircuits is the number of endpoints configured with WithEndpoints currently out of rotation,
+	// because they failed their health check or were ejected by WithOutlierDetection.
+	OpenCircuits int
+	// RetriesDropped is the number of retries dropped by WithRetryDamping, and RetriesShed the number of
+	// those shed by WithRetryShedding.
+	RetriesDropped uint64
+	RetriesShed    uint64
+	// NewConns and ReusedConns count the attempts sent over a new connection and over a pooled
+	// keep-alive connection. Retries mostly opening new connections defeat keep-alive, e.g. because
+	// the server closes connections after errors.
+	NewConns    uint64
+	ReusedConns uint64
+	// AuditDropped is the number of audit records dropped because the audit queue was full.
+	AuditDropped uint64
+}
+
+// clientStats holds the live counters behind ClientStats.
+type clientStats struct {
+	requests  atomic.Uint64
+	retries   atomic.Uint64
+	successes atomic.Uint64
+	failures  atomic.Uint64
+	inFlight  atomic.Int64
+	retrying  atomic.Int64
+
+	retriesDropped atomic.Uint64
+	retriesShed    atomic.Uint64
+
+	newConns    atomic.Uint64
+	reusedConns atomic.Uint64
+
+	auditDropped atomic.Uint64
+}
+
+// Stats returns a snapshot of the client's operational counters.
+func (c *Client) Stats() ClientStats {
+	return ClientStats{
+		Requests:  c.stats.requests.Load(),
+		Retries:   c.stats.retries.Load(),
+		Successes: c.stats.successes.Load(),
+		Failures:  c.stats.failures.Load(),
+		InFlight:  c.stats.inFlight.Load(),
+		Retrying:  c.stats.retrying.Load(),
+
+		OpenCircuits: c.endpoints.open(time.Now()),
+
+		RetriesDropped: c.stats.retriesDropped.Load(),
+		RetriesShed:    c.stats.retriesShed.Load(),
+
+		NewConns:    c.stats.newConns.Load(),
+		ReusedConns: c.stats.reusedConns.Load(),
+
+		AuditDropped: c.stats.auditDropped.Load(),
+	}
+}
+
+// PublishExpvar publishes the client's statistics as an expvar variable with the given name,
+// served as JSON by the expvar handler. Names are global to the process and can only be published once.
+func (c *Client) PublishExpvar(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty expvar name")
+	}
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar '%s' already published", name)
+	}
+
+	expvar.Publish(name, expvar.Func(func() any {
+		return c.Stats()
+	}))
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// WithRetryStatusCodes restricts retries to responses with one of the given status codes.
+// Responses with a listed status are always retried, while any other response rejected by
+// the policy fails immediately instead of consuming the remaining attempts.
+func WithRetryStatusCodes(codes ...int) ClientOption {
+	return func(c *Client) error {
+		if err := validateStatusCodes(codes); err != nil {
+			return err
+		}
+		c.retryStatuses = codes
+
+		return nil
+	}
+}
+
+// WithNoRetryStatusCodes makes responses with one of the given status codes fail immediately
+// when rejected by the policy, without consuming the remaining attempts.
+func WithNoRetryStatusCodes(codes ...int) ClientOption {
+	return func(c *Client) error {
+		if err := validateStatusCodes(codes); err != nil {
+			return err
+		}
+		c.noRetryStatuses = codes
+
+		return nil
+	}
+}
+
+func validateStatusCodes(codes []int) error {
+	if len(codes) == 0 {
+		return fmt.Errorf("no status codes specified")
+	}
+
+	for _, code := range codes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid status code '%d'", code)
+		}
+	}
+
+	return nil
+}
+
+// applyStatusCodes adjusts the policy outcome for a response according to the configured status code lists.
+func (c *Client) applyStatusCodes(resp *http.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+
+	if len(c.retryStatuses) > 0 {
+		if slices.Contains(c.retryStatuses, resp.StatusCode) {
+			if err == nil {
+				err = fmt.Errorf("HTTP response status code (%d) marked for retry", resp.StatusCode)
+			}
+
+			return err
+		}
+
+		return backoffpolicy.Permanent(err)
+	}
+
+	if err != nil && slices.Contains(c.noRetryStatuses, resp.StatusCode) {
+		return backoffpolicy.Permanent(err)
+	}
+
+	return err
+}
@@ -0,0 +1,38 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithResponseTee copies the response body to w as the caller reads it, e.g. to a file, a hash or an
+// audit log. Only the body of the response returned to the caller is copied: the bodies of rejected
+// attempts never reach w, so a retry doesn't leave a partial copy behind. A failure to write to w fails
+// the read of the body.
+func WithResponseTee(w io.Writer) RequestOption {
+	return func(ro *requestOptions) error {
+		if w == nil {
+			return fmt.Errorf("nil response tee writer")
+		}
+		ro.tee = w
+
+		return nil
+	}
+}
+
+// teeBody is a response body copied to a writer as it's read.
+type teeBody struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if _, writeErr := t.w.Write(p[:n]); writeErr != nil {
+			return n, fmt.Errorf("failed to write response body to tee: %w", writeErr)
+		}
+	}
+
+	return n, err
+}
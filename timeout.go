@@ -0,0 +1,104 @@
+package retryablehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBodyReadTimeout reports a response body that wasn't read within the timeout set with
+// WithBodyReadTimeout.
+var ErrBodyReadTimeout = errors.New("response body read timeout")
+
+// WithTimeout bounds every request by an overall deadline of d, covering its attempts, the backoff
+// between them and the read of the response body, like http.Client.Timeout does for a single attempt.
+// The deadline is set on the request context, so retries that can't complete before it are skipped,
+// and the request fails with ErrDeadlineExceeded once it expires; a Timeout set on a custom http.Client
+// still applies to each attempt on its own. Zero means no timeout.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("invalid timeout value '%s'", d)
+		}
+		c.timeout = d
+
+		return nil
+	}
+}
+
+// WithBodyReadTimeout bounds the time to read the response body of each attempt, from the moment its
+// headers are received, complementing the dial, TLS handshake and response header timeouts of the
+// transport so slow downloads aren't cut short by a single timeout covering every phase. Reads past the
+// timeout fail with ErrBodyReadTimeout, and so does the attempt when the client reads the body itself,
+// e.g. to validate it, in which case it's retried. Zero means no timeout.
+func WithBodyReadTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("invalid body read timeout value '%s'", d)
+		}
+		c.bodyReadTimeout = d
+
+		return nil
+	}
+}
+
+// bodyDeadline bounds the time to read the body of an attempt's response, cancelling the attempt
+// context once it expires.
+type bodyDeadline struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// newBodyDeadline returns the context of an attempt whose body read is bounded by timeout.
+func newBodyDeadline(ctx context.Context, timeout time.Duration) (context.Context, *bodyDeadline) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return ctx, &bodyDeadline{timeout: timeout, cancel: cancel}
+}
+
+// watch starts the timeout for reading the body of resp. Without a response, it releases the attempt
+// context right away.
+func (d *bodyDeadline) watch(resp *http.Response) {
+	if resp == nil {
+		d.cancel()
+		return
+	}
+
+	d.timer = time.AfterFunc(d.timeout, func() {
+		d.expired.Store(true)
+		d.cancel()
+	})
+	resp.Body = &deadlineBody{ReadCloser: resp.Body, deadline: d}
+}
+
+// deadlineBody is a response body read within a bodyDeadline.
+type deadlineBody struct {
+	io.ReadCloser
+	deadline *bodyDeadline
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF:
+		b.deadline.timer.Stop()
+	case err != nil && b.deadline.expired.Load():
+		err = fmt.Errorf("%w after %s", ErrBodyReadTimeout, b.deadline.timeout)
+	}
+
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.deadline.timer.Stop()
+	b.deadline.cancel()
+
+	return err
+}
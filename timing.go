@@ -0,0 +1,176 @@
+package retryablehttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AttemptTiming describes the timing and outcome of a single attempt.
+type AttemptTiming struct {
+	Start time.Time
+	End   time.Time
+	// Delay is the time waited since the previous attempt ended, zero for the first attempt.
+	Delay time.Duration
+	// DNS, Connect, TLSHandshake and TimeToFirstByte break the attempt down into phases when tracing
+	// is enabled with WithAttemptTracing. Phases that didn't happen, e.g. on a reused connection, are zero.
+	DNS             time.Duration
+	Connect         time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	// ConnReused reports whether the attempt was sent over a pooled keep-alive connection rather than a
+	// new one, and ConnIdleTime how long that connection had been idle.
+	ConnReused   bool
+	ConnIdleTime time.Duration
+	// StatusCode is the status of the attempt response, or 0 if there's none.
+	StatusCode int
+	// Err is the error that failed the attempt, if any.
+	Err error
+}
+
+// TimingReport describes the attempts made for a request, see WithTimingReport.
+type TimingReport struct {
+	// RequestID is the ID assigned to the request, if request IDs are enabled.
+	RequestID string
+	Method    string
+	URL       string
+	Attempts  []AttemptTiming
+	// Total is the time from the start of the first attempt to the end of the last one.
+	Total time.Duration
+	// Err is the error the request failed with, if any.
+	Err error
+}
+
+// WithAttemptTracing breaks the timing of every attempt down into DNS lookup, connection, TLS
+// handshake and time to first byte, using net/http/httptrace.
+func WithAttemptTracing() ClientOption {
+	return func(c *Client) error {
+		c.attemptTracing = true
+
+		return nil
+	}
+}
+
+// WithTimingReport calls fn with the timing of every attempt once a request completes, successfully
+// or not. The same timings are available from a response through RetryInfoFromResponse.
+func WithTimingReport(fn func(TimingReport)) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil timing report function")
+		}
+		c.onTimingReport = fn
+
+		return nil
+	}
+}
+
+// attemptTracer records the phases of an attempt reported by httptrace.
+type attemptTracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       AttemptTiming
+}
+
+// trace returns ctx with the tracer hooks installed.
+func (t *attemptTracer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.timing.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil && t.timing.Connect == 0 {
+				t.timing.Connect = time.Since(t.connectStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.timing.TLSHandshake = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.timing.TimeToFirstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	})
+}
+
+// connUse records the connection an attempt was sent over.
+type connUse struct {
+	reused atomic.Bool
+	idle   atomic.Int64
+}
+
+// traceConn returns ctx with a hook recording the connection of the attempt, and counting new and
+// reused connections in the client stats.
+func (c *Client) traceConn(ctx context.Context) (context.Context, *connUse) {
+	use := &connUse{}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			use.reused.Store(info.Reused)
+			use.idle.Store(int64(info.IdleTime))
+			if info.Reused {
+				c.stats.reusedConns.Add(1)
+			} else {
+				c.stats.newConns.Add(1)
+			}
+		},
+	})
+
+	return ctx, use
+}
+
+// phases returns the phase timings recorded so far.
+func (t *attemptTracer) phases() AttemptTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.timing
+}
+
+// reportTiming calls the timing report hook, if configured.
+func (c *Client) reportTiming(info *RetryInfo, requestID, method, url string, err error) {
+	if c.onTimingReport == nil {
+		return
+	}
+
+	report := TimingReport{
+		RequestID: requestID,
+		Method:    method,
+		URL:       url,
+		Attempts:  info.Timings,
+		Err:       err,
+	}
+	if n := len(info.Timings); n > 0 {
+		report.Total = info.Timings[n-1].End.Sub(info.Timings[0].Start)
+	}
+	c.onTimingReport(report)
+}
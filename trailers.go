@@ -0,0 +1,68 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// WithTrailer sets a request trailer, sent after the body. Requests with trailers are sent with
+// chunked transfer encoding, as trailers can't follow a body of declared length.
+func WithTrailer(name, value string) RequestOption {
+	return func(ro *requestOptions) error {
+		if name == "" {
+			return fmt.Errorf("empty trailer name")
+		}
+		if ro.trailer == nil {
+			ro.trailer = http.Header{}
+		}
+		ro.trailer.Add(name, value)
+
+		return nil
+	}
+}
+
+// WithResponseTrailers calls fn with the response trailers once the caller has read the response
+// body to the end, which is when trailers become available.
+func WithResponseTrailers(fn func(trailer http.Header)) RequestOption {
+	return func(ro *requestOptions) error {
+		if fn == nil {
+			return fmt.Errorf("nil response trailers function")
+		}
+		ro.onTrailers = fn
+
+		return nil
+	}
+}
+
+// applyTrailers declares the request trailers on req, switching its body to chunked encoding.
+func applyTrailers(req *http.Request, trailer http.Header) {
+	if len(trailer) == 0 {
+		return
+	}
+
+	req.Trailer = trailer.Clone()
+	if req.Body != nil && req.Body != http.NoBody {
+		req.ContentLength = -1
+	}
+}
+
+// trailerNotifier calls onTrailers with the response trailers once the body reaches EOF.
+type trailerNotifier struct {
+	io.ReadCloser
+	resp       *http.Response
+	once       sync.Once
+	onTrailers func(http.Header)
+}
+
+func (t *trailerNotifier) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if err == io.EOF {
+		t.once.Do(func() {
+			t.onTrailers(t.resp.Trailer)
+		})
+	}
+
+	return n, err
+}
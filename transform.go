@@ -0,0 +1,118 @@
+package retryablehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ResponseTransform rewrites the response of an attempt before the policy and the caller see it, e.g.
+// replacing its body or status code. An error fails the attempt in place of the policy, so vendor error
+// codes can be mapped to typed errors: it's retried unless wrapped with backoffpolicy.Permanent.
+type ResponseTransform func(resp *http.Response) error
+
+// WithResponseTransform applies transform to the response of every attempt. Transforms run in the order
+// they're added, before the one of the route of the request, see WithRouteResponseTransform.
+func WithResponseTransform(transform ResponseTransform) ClientOption {
+	return func(c *Client) error {
+		if transform == nil {
+			return fmt.Errorf("nil response transform")
+		}
+		c.transforms = append(c.transforms, transform)
+
+		return nil
+	}
+}
+
+// WithRouteResponseTransform applies transform to the response of every attempt of the requests
+// matching pattern. Patterns follow the syntax of http.ServeMux, e.g. "GET api.example.com/users/{id}"
+// or "/orders/", and only the transform of the most specific pattern matching a request applies.
+func WithRouteResponseTransform(pattern string, transform ResponseTransform) ClientOption {
+	return func(c *Client) (err error) {
+		if transform == nil {
+			return fmt.Errorf("nil response transform for route '%s'", pattern)
+		}
+		if c.routeTransforms == nil {
+			c.routeTransforms = &routeTransforms{mux: http.NewServeMux(), transforms: map[string]ResponseTransform{}}
+		}
+
+		// The mux only matches routes; its handlers are never called. It panics on invalid or
+		// conflicting patterns.
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("invalid route '%s': %v", pattern, r)
+			}
+		}()
+		c.routeTransforms.mux.Handle(pattern, http.NotFoundHandler())
+		c.routeTransforms.transforms[pattern] = transform
+
+		return nil
+	}
+}
+
+// routeTransforms holds the response transform of each route pattern.
+type routeTransforms struct {
+	mux        *http.ServeMux
+	transforms map[string]ResponseTransform
+}
+
+// transform returns the transform of the route matching req, if any.
+func (r *routeTransforms) transform(req *http.Request) ResponseTransform {
+	// Match against the target host, which client requests leave out of req.Host.
+	if req.Host == "" {
+		req = req.Clone(req.Context())
+		req.Host = req.URL.Host
+	}
+	_, pattern := r.mux.Handler(req)
+
+	return r.transforms[pattern]
+}
+
+// transformResponse applies the client and route transforms to resp, the response to req.
+func (c *Client) transformResponse(req *http.Request, resp *http.Response) error {
+	transforms := c.transforms
+	if c.routeTransforms != nil {
+		if t := c.routeTransforms.transform(req); t != nil {
+			transforms = append(transforms[:len(transforms):len(transforms)], t)
+		}
+	}
+
+	for _, t := range transforms {
+		if err := t(resp); err != nil {
+			return fmt.Errorf("response transform failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UnwrapEnvelope returns a ResponseTransform replacing the body of 2xx JSON responses with the value
+// of their field member, e.g. unwrapping {"data": {...}} with UnwrapEnvelope("data"). Responses whose
+// body isn't a JSON object holding field are left untouched.
+func UnwrapEnvelope(field string) ResponseTransform {
+	return func(resp *http.Response) error {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil
+		}
+
+		body, err := bufferResponseBody(resp)
+		if err != nil {
+			return err
+		}
+		var envelope map[string]json.RawMessage
+		if json.Unmarshal(body, &envelope) != nil {
+			return nil
+		}
+		data, ok := envelope[field]
+		if !ok {
+			return nil
+		}
+
+		resp.Body = newBufferedBody(data)
+		resp.ContentLength = int64(len(data))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+
+		return nil
+	}
+}
@@ -0,0 +1,330 @@
+package retryablehttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// defaultDialTimeout and defaultKeepAlive are the dial timeout and keep-alive period of the connections
+// dialled by the managed transport, as with http.DefaultTransport.
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// newManagedTransport returns the transport used when no custom http.Client is provided,
+// starting from the settings of http.DefaultTransport.
+func newManagedTransport() *http.Transport {
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// freshConnections sends the retries following a connection reset over new connections.
+type freshConnections struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// WithFreshConnectionOnReset sends the retry following a connection reset (see ErrorClassConnectionReset)
+// over a new connection rather than a pooled keep-alive connection, which may have gone stale the same
+// way. With a custom http.Client whose transport isn't an *http.Transport, the idle connections of the
+// client are closed instead.
+func WithFreshConnectionOnReset() ClientOption {
+	return func(c *Client) error {
+		c.freshConnections = &freshConnections{}
+
+		return nil
+	}
+}
+
+// freshConnectionClient returns the client sending attempts over new connections, built on first use
+// from a clone of the transport with keep-alives disabled.
+func (c *Client) freshConnectionClient() *http.Client {
+	f := c.freshConnections
+	f.once.Do(func() {
+		rt := c.httpClient.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			return
+		}
+		t = t.Clone()
+		t.DisableKeepAlives = true
+		client := *c.httpClient
+		client.Transport = t
+		f.client = &client
+	})
+
+	if f.client == nil {
+		c.httpClient.CloseIdleConnections()
+		return c.httpClient
+	}
+
+	return f.client
+}
+
+// transportOption returns a ClientOption that configures the managed transport.
+// Transport options can't be combined with WithHttpClient, since the client then doesn't own the transport.
+func transportOption(configure func(t *http.Transport) error) ClientOption {
+	return func(c *Client) error {
+		if err := configure(c.transport); err != nil {
+			return err
+		}
+		c.transportConfigured = true
+
+		return nil
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle keep-alive connection stays open. Zero means no limit.
+func WithIdleConnTimeout(d time.Duration) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if d < 0 {
+			return fmt.Errorf("invalid idle conn timeout value '%s'", d)
+		}
+		t.IdleConnTimeout = d
+
+		return nil
+	})
+}
+
+// dialerOption returns a ClientOption that configures the dialer of the managed transport, shared by
+// every dialer option.
+func dialerOption(configure func(d *net.Dialer) error) ClientOption {
+	return func(c *Client) error {
+		if c.dialer == nil {
+			c.dialer = &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}
+		}
+		if err := configure(c.dialer); err != nil {
+			return err
+		}
+
+		return transportOption(func(t *http.Transport) error {
+			t.DialContext = c.dialer.DialContext
+
+			return nil
+		})(c)
+	}
+}
+
+// WithDialTimeout sets the maximum time to wait for a connection to be established. Zero means no
+// timeout, though the operating system may still enforce one.
+func WithDialTimeout(d time.Duration) ClientOption {
+	return dialerOption(func(dialer *net.Dialer) error {
+		if d < 0 {
+			return fmt.Errorf("invalid dial timeout value '%s'", d)
+		}
+		dialer.Timeout = d
+
+		return nil
+	})
+}
+
+// WithResolver resolves the host names of every connection of the managed transport with r, including
+// those to proxies, e.g. to query specific DNS servers, follow split-horizon DNS or resolve over DoT
+// with a custom r.Dial.
+func WithResolver(r *net.Resolver) ClientOption {
+	return dialerOption(func(dialer *net.Dialer) error {
+		if r == nil {
+			return fmt.Errorf("nil resolver")
+		}
+		dialer.Resolver = r
+
+		return nil
+	})
+}
+
+// WithTLSHandshakeTimeout sets the maximum time to wait for a TLS handshake. Zero means no timeout.
+func WithTLSHandshakeTimeout(d time.Duration) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if d < 0 {
+			return fmt.Errorf("invalid tls handshake timeout value '%s'", d)
+		}
+		t.TLSHandshakeTimeout = d
+
+		return nil
+	})
+}
+
+// WithTLSServerName sets the server name sent with TLS handshakes (SNI) and used to verify the server
+// certificate, instead of the host of the request URL, e.g. to reach an IP address or an internal load
+// balancer while validating the certificate of the public name. It applies to every TLS connection of
+// the client, including those to HTTPS proxies.
+func WithTLSServerName(name string) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if name == "" {
+			return fmt.Errorf("empty tls server name")
+		}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.ServerName = name
+
+		return nil
+	})
+}
+
+// TLSProfile is a preset of the TLS versions and cipher suites the client negotiates, see WithTLSProfile.
+type TLSProfile string
+
+const (
+	// TLSProfileModern only allows TLS 1.3, following the "modern" configuration of Mozilla.
+	TLSProfileModern TLSProfile = "modern"
+	// TLSProfileIntermediate allows TLS 1.2 with forward-secret AEAD cipher suites, and TLS 1.3,
+	// following the "intermediate" configuration of Mozilla.
+	TLSProfileIntermediate TLSProfile = "intermediate"
+	// TLSProfileFIPS allows TLS 1.2 with the ECDHE and AES-GCM cipher suites and TLS 1.3, over the
+	// P-256 and P-384 curves, as approved by FIPS 140. crypto/tls doesn't let TLS 1.3 cipher suites be
+	// configured: they're only restricted to AES-GCM when the binary runs in FIPS 140 mode.
+	TLSProfileFIPS TLSProfile = "fips"
+)
+
+// tlsProfileCipherSuites lists the TLS 1.2 cipher suites of each profile.
+var tlsProfileCipherSuites = map[TLSProfile][]uint16{
+	TLSProfileIntermediate: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	},
+	TLSProfileFIPS: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	},
+}
+
+// WithTLSProfile restricts the TLS versions, cipher suites and, for TLSProfileFIPS, key exchange curves
+// negotiated by the managed transport to those of profile, for compliance regimes requiring them. It
+// applies to every TLS connection of the client, including those to HTTPS proxies.
+func WithTLSProfile(profile TLSProfile) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		cfg := t.TLSClientConfig
+
+		switch profile {
+		case TLSProfileModern:
+			cfg.MinVersion, cfg.CipherSuites, cfg.CurvePreferences = tls.VersionTLS13, nil, nil
+		case TLSProfileIntermediate:
+			cfg.MinVersion, cfg.CipherSuites, cfg.CurvePreferences = tls.VersionTLS12, tlsProfileCipherSuites[profile], nil
+		case TLSProfileFIPS:
+			cfg.MinVersion, cfg.CipherSuites = tls.VersionTLS12, tlsProfileCipherSuites[profile]
+			cfg.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+		default:
+			return fmt.Errorf("invalid tls profile '%s'", profile)
+		}
+
+		return nil
+	})
+}
+
+// WithResponseHeaderTimeout sets the maximum time to wait for the response headers once the
+// request has been written. Zero means no timeout.
+func WithResponseHeaderTimeout(d time.Duration) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if d < 0 {
+			return fmt.Errorf("invalid response header timeout value '%s'", d)
+		}
+		t.ResponseHeaderTimeout = d
+
+		return nil
+	})
+}
+
+// WithExpectContinueTimeout sets the maximum time to wait for a 100-continue response when the
+// request has an "Expect: 100-continue" header. Zero sends the body immediately.
+func WithExpectContinueTimeout(d time.Duration) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if d < 0 {
+			return fmt.Errorf("invalid expect continue timeout value '%s'", d)
+		}
+		t.ExpectContinueTimeout = d
+
+		return nil
+	})
+}
+
+// WithMaxIdleConnsPerHost sets the number of idle keep-alive connections kept per host.
+// The net/http default of 2 makes retry-heavy workloads churn connections.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if n < 1 {
+			return fmt.Errorf("invalid max idle conns per host value '%d'", n)
+		}
+		t.MaxIdleConnsPerHost = n
+		// Keep the overall idle pool from capping the per-host one.
+		if t.MaxIdleConns != 0 && t.MaxIdleConns < n {
+			t.MaxIdleConns = n
+		}
+
+		return nil
+	})
+}
+
+// WithMaxConnsPerHost limits the total number of connections per host, whether dialing, active or idle.
+// Zero means no limit.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max conns per host value '%d'", n)
+		}
+		t.MaxConnsPerHost = n
+
+		return nil
+	})
+}
+
+// defaultExpectContinueTimeout is used by WithExpectContinue when the managed transport has no
+// continue timeout, in which case net/http would send the body without waiting.
+const defaultExpectContinueTimeout = time.Second
+
+// WithExpectContinue sends requests that have a body with an "Expect: 100-continue" header, so the
+// body is only transmitted once the server has accepted the request headers. This saves uploading
+// large bodies to servers that are going to reject them anyway. The managed transport waits for
+// the 100-continue response up to its continue timeout (see WithExpectContinueTimeout); with a
+// custom http.Client, its transport must have a non-zero ExpectContinueTimeout for this to apply.
+func WithExpectContinue(enabled bool) ClientOption {
+	return func(c *Client) error {
+		c.expectContinue = enabled
+		if enabled && !c.customHttpClient && c.transport.ExpectContinueTimeout == 0 {
+			c.transport.ExpectContinueTimeout = defaultExpectContinueTimeout
+		}
+
+		return nil
+	}
+}
+
+// applyExpectContinue sets the Expect header on req when enabled and req has a body.
+func (c *Client) applyExpectContinue(req *http.Request) {
+	hasBody := req.ContentLength != 0 || (req.Body != nil && req.Body != http.NoBody)
+	if !c.expectContinue || !hasBody || req.Header.Get("Expect") != "" {
+		return
+	}
+
+	req.Header.Set("Expect", "100-continue")
+}
+
+// WithProxyFunc chooses the proxy of each attempt with proxy, e.g. per region or per tenant from a
+// request context value, replacing the proxies set in the environment. A nil URL sends the attempt
+// directly. Connections are pooled per proxy, and WithProxyAuth applies to the chosen proxies.
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return transportOption(func(t *http.Transport) error {
+		if proxy == nil {
+			return fmt.Errorf("nil proxy function")
+		}
+		t.Proxy = proxy
+
+		return nil
+	})
+}
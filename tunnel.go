@@ -0,0 +1,180 @@
+package retryablehttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// DialTunnel opens a tunnel to target, a "host:port" address, through the HTTP proxy at proxyURL with
+// a CONNECT request, and returns the tunnelled connection, e.g. for protocols other than HTTP. The
+// proxy is dialled with the dialer and TLS configuration of the transport, over TLS for "https" proxy
+// URLs, and credentials in proxyURL, or else those of WithProxyAuth, are sent as Proxy-Authorization.
+// Failed attempts are retried like requests on a new connection, the policy deciding on the CONNECT
+// responses.
+func (c *Client) DialTunnel(ctx context.Context, proxyURL, target string) (net.Conn, error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid proxy url '%s': %w", proxyURL, err)}
+	}
+	if (proxy.Scheme != "http" && proxy.Scheme != "https") || proxy.Host == "" {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid proxy url '%s': expected an http or https url", proxyURL)}
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return nil, &ConfigError{Err: fmt.Errorf("invalid tunnel target '%s': %w", target, err)}
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		connectReq.Header.Set("Proxy-Authorization", proxyAuthorization(proxy.User))
+	}
+
+	settings := c.settingsFor(&http.Request{Method: http.MethodConnect, URL: proxy}, nil)
+	curve, err := settings.backoffCurve()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conn     net.Conn
+		attempts uint32
+	)
+	err = backoffpolicy.BackoffPolicyCurve(ctx, curve, settings.attempts, func(backoffpolicy.Attempt) error {
+		attempts++
+
+		// Present the client proxy credentials, fetched again after the proxy refused them.
+		fromClient := proxy.User == nil && c.proxyAuth != nil
+		if fromClient {
+			user, err := c.proxyAuth.userinfo(ctx)
+			if err != nil {
+				return err
+			}
+			connectReq.Header.Set("Proxy-Authorization", proxyAuthorization(user))
+		}
+
+		var resp *http.Response
+		conn, resp, err = c.connect(ctx, proxy, connectReq)
+		if fromClient && proxyAuthRequired(resp, err) {
+			c.proxyAuth.invalidate()
+		}
+		policyErr := settings.policy(resp, err)
+
+		// Only a 2xx response establishes the tunnel, whatever the policy accepts: an attempt that got no
+		// connection failed even if the policy tolerates its error.
+		switch {
+		case policyErr != nil:
+		case err != nil:
+			policyErr = err
+		case conn == nil:
+			policyErr = fmt.Errorf("no connection to proxy")
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			policyErr = backoffpolicy.Permanent(fmt.Errorf("proxy refused tunnel with status code (%d)", resp.StatusCode))
+		}
+		if policyErr != nil && conn != nil {
+			conn.Close()
+			conn = nil
+		}
+
+		return policyErr
+	})
+	if err != nil {
+		return nil, newRetryError(ctx, attempts, fmt.Errorf("backoff policy expired: %w", err))
+	}
+
+	return conn, nil
+}
+
+// connect dials proxy and sends req, returning the connection along with the proxy response.
+func (c *Client) connect(ctx context.Context, proxy *url.URL, req *http.Request) (net.Conn, *http.Response, error) {
+	transport, _ := c.httpClient.Transport.(*http.Transport)
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	address := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial proxy: %w", err)
+	}
+
+	if proxy.Scheme == "https" {
+		cfg := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			cfg = transport.TLSClientConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = proxy.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to establish tls with proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	// Interrupt the exchange if the context is done before the proxy answers.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send connect request: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to read connect response: %w", err)
+	}
+	resp.Body.Close()
+
+	if !stop() {
+		conn.Close()
+		return nil, nil, context.Cause(ctx)
+	}
+
+	// Keep the bytes the proxy may have sent past its response.
+	if br.Buffered() > 0 {
+		conn = &bufferedConn{Conn: conn, r: br}
+	}
+
+	return conn, resp, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,231 @@
+package retryablehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+const (
+	// tusVersion is the version of the tus protocol spoken by UploadTus.
+	tusVersion = "1.0.0"
+	// defaultTusChunkSize is the size of the PATCH requests of a tus upload when none is configured.
+	defaultTusChunkSize = 4 << 20
+)
+
+// TusUpload configures UploadTus. Zero-valued fields select the defaults.
+type TusUpload struct {
+	// UploadURL resumes an upload created earlier. When empty, a new upload is created.
+	UploadURL string
+	// Metadata is sent in the Upload-Metadata header of a new upload.
+	Metadata map[string]string
+	// ChunkSize is the maximum size of a PATCH request. It defaults to 4 MiB.
+	ChunkSize int64
+	// OnCreated is called with the URL of a new upload, so it can be persisted to resume the upload
+	// after the process restarts.
+	OnCreated func(uploadURL string)
+	// OnProgress is called with the offset confirmed by the server after every chunk.
+	OnProgress func(offset, size int64)
+}
+
+// withAttempts overrides the number of attempts and the policy of a request.
+func withAttempts(attempts uint32, policy Policy) RequestOption {
+	return func(ro *requestOptions) error {
+		ro.attempts, ro.policy = attempts, policy
+
+		return nil
+	}
+}
+
+// UploadTus uploads size bytes read from r to a tus (https://tus.io) server, creating the upload at
+// endpoint unless cfg.UploadURL resumes an existing one, and returns the upload URL. Content is sent
+// in chunks; when sending a chunk fails, the offset confirmed by the server is fetched again and the
+// upload resumes from there, waiting according to the client backoff strategy, with up to as many
+// attempts per chunk as the client allows. The creation and offset requests are retried as usual.
+func (c *Client) UploadTus(ctx context.Context, endpoint string, r io.ReaderAt, size int64, cfg TusUpload) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("nil upload reader")
+	}
+	if size < 0 {
+		return "", fmt.Errorf("invalid upload size value '%d'", size)
+	}
+	if cfg.ChunkSize < 0 {
+		return "", fmt.Errorf("invalid chunk size value '%d'", cfg.ChunkSize)
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = defaultTusChunkSize
+	}
+
+	uploadURL := cfg.UploadURL
+	offset := int64(0)
+	if uploadURL == "" {
+		var err error
+		if uploadURL, err = c.createTusUpload(ctx, endpoint, size, cfg.Metadata); err != nil {
+			return "", err
+		}
+		if cfg.OnCreated != nil {
+			cfg.OnCreated(uploadURL)
+		}
+	} else {
+		var err error
+		if offset, err = c.tusOffset(ctx, uploadURL); err != nil {
+			return uploadURL, err
+		}
+	}
+
+	curve, err := c.defaultSettings().backoffCurve()
+	if err != nil {
+		return uploadURL, err
+	}
+
+	for offset < size {
+		// Every chunk gets the full attempt budget; retries resume from the server-confirmed offset.
+		err := backoffpolicy.BackoffPolicyCurve(ctx, curve, c.attempts, func(a backoffpolicy.Attempt) error {
+			if a.Number > 0 {
+				confirmed, err := c.tusOffset(ctx, uploadURL)
+				if err != nil {
+					return backoffpolicy.Permanent(err)
+				}
+				offset = confirmed
+				if offset >= size {
+					return nil
+				}
+			}
+
+			next, err := c.patchTusChunk(ctx, uploadURL, r, offset, min(cfg.ChunkSize, size-offset))
+			if err != nil {
+				return err
+			}
+			offset = next
+
+			return nil
+		})
+		if err != nil {
+			return uploadURL, fmt.Errorf("failed to upload tus chunk at offset %d: %w", offset, err)
+		}
+
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(offset, size)
+		}
+	}
+
+	return uploadURL, nil
+}
+
+// createTusUpload creates an upload of size bytes at endpoint and returns its URL.
+func (c *Client) createTusUpload(ctx context.Context, endpoint string, size int64, metadata map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tus upload request: %w", err)
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	if len(metadata) > 0 {
+		req.Header.Set("Upload-Metadata", encodeTusMetadata(metadata))
+	}
+
+	resp, err := c.DoRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tus upload: %w", err)
+	}
+	resp.Body.Close()
+
+	location, err := req.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return "", fmt.Errorf("tus upload created without a valid location")
+	}
+
+	return location.String(), nil
+}
+
+// tusOffset returns the offset of the upload confirmed by the server.
+func (c *Client) tusOffset(ctx context.Context, uploadURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, uploadURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tus offset request: %w", err)
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+
+	resp, err := c.DoRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch tus upload offset: %w", err)
+	}
+	resp.Body.Close()
+
+	return parseTusOffset(resp)
+}
+
+// patchTusChunk sends length bytes of r from offset in a single attempt, and returns the new offset.
+func (c *Client) patchTusChunk(ctx context.Context, uploadURL string, r io.ReaderAt, offset, length int64) (int64, error) {
+	chunk := make([]byte, length)
+	if n, err := r.ReadAt(chunk, offset); n < len(chunk) {
+		// Content shorter than the upload size would otherwise be padded with zeros.
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return offset, backoffpolicy.Permanent(fmt.Errorf("failed to read upload content at offset %d: %w", offset+int64(n), err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, uploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return offset, backoffpolicy.Permanent(fmt.Errorf("failed to create tus patch request: %w", err))
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	// A failed chunk is resumed from the server offset rather than sent again as is, so the
+	// response is inspected here whatever its status.
+	resp, err := c.DoRequest(req, withAttempts(1, func(_ *http.Response, err error) error { return err }))
+	if err != nil {
+		return offset, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		next, err := parseTusOffset(resp)
+		if err != nil {
+			return offset, err
+		}
+		// An upload that doesn't move forward would be patched forever.
+		if next <= offset {
+			return offset, fmt.Errorf("tus upload offset '%d' did not advance past '%d'", next, offset)
+		}
+		return next, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The upload is gone, resuming it is pointless.
+		return offset, backoffpolicy.Permanent(fmt.Errorf("tus upload not found, HTTP response status code (%d)", resp.StatusCode))
+	default:
+		return offset, fmt.Errorf("HTTP response status code (%d) outside boundaries", resp.StatusCode)
+	}
+}
+
+// parseTusOffset reads the Upload-Offset header of resp.
+func parseTusOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid tus upload offset '%s'", resp.Header.Get("Upload-Offset"))
+	}
+
+	return offset, nil
+}
+
+// encodeTusMetadata encodes metadata as an Upload-Metadata header value, with sorted keys.
+func encodeTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, ",")
+}
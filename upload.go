@@ -0,0 +1,180 @@
+package retryablehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+const (
+	// defaultChunkSize is the size of the parts of a chunked upload when none is configured.
+	defaultChunkSize = 8 << 20
+	// defaultUploadParallelism is the number of parts uploaded concurrently when none is configured.
+	defaultUploadParallelism = 4
+)
+
+// UploadedPart identifies a part of a chunked upload accepted by the server.
+type UploadedPart struct {
+	// Number is the 1-based position of the part in the upload.
+	Number int
+	// ID is the identifier the server assigned to the part, e.g. its ETag.
+	ID   string
+	Size int64
+}
+
+// MultipartUploader implements the protocol of a chunked upload, e.g. S3 multipart uploads, for
+// UploadChunked.
+type MultipartUploader interface {
+	// Init starts an upload and returns its ID.
+	Init(ctx context.Context) (uploadID string, err error)
+	// PartRequest builds the request uploading the part with the given number and body.
+	PartRequest(ctx context.Context, uploadID string, number int, body []byte) (*http.Request, error)
+	// PartID extracts the identifier of the uploaded part from the response to its request.
+	PartID(number int, resp *http.Response) (string, error)
+	// Complete finishes the upload, given every part in order.
+	Complete(ctx context.Context, uploadID string, parts []UploadedPart) error
+	// Abort discards an upload that failed.
+	Abort(ctx context.Context, uploadID string) error
+}
+
+// ChunkedUpload configures UploadChunked. Zero-valued fields select the defaults.
+type ChunkedUpload struct {
+	// ChunkSize is the size of every part but the last one. It defaults to 8 MiB.
+	ChunkSize int64
+	// Parallelism is the number of parts uploaded concurrently. It defaults to 4.
+	Parallelism int
+}
+
+// UploadChunked uploads the content of r in parts of cfg.ChunkSize through u. Every part is sent
+// with the retry logic of the client on its own, so a failure only repeats the affected part rather
+// than the whole upload. Up to cfg.Parallelism parts are held in memory and uploaded at once. If a
+// part fails for good, the remaining parts are cancelled and the upload is aborted.
+func (c *Client) UploadChunked(ctx context.Context, r io.Reader, u MultipartUploader, cfg ChunkedUpload) ([]UploadedPart, error) {
+	if u == nil {
+		return nil, fmt.Errorf("nil multipart uploader")
+	}
+	if cfg.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size value '%d'", cfg.ChunkSize)
+	}
+	if cfg.Parallelism < 0 {
+		return nil, fmt.Errorf("invalid parallelism value '%d'", cfg.Parallelism)
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = defaultUploadParallelism
+	}
+
+	uploadID, err := u.Init(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init chunked upload: %w", err)
+	}
+
+	partsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []UploadedPart
+		firstErr error
+		slots    = make(chan struct{}, cfg.Parallelism)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+read:
+	for number := 1; ; number++ {
+		// Wait for a free slot before reading the next chunk, so that memory stays bounded.
+		select {
+		case slots <- struct{}{}:
+		case <-partsCtx.Done():
+			break read
+		}
+
+		chunk := make([]byte, cfg.ChunkSize)
+		n, readErr := io.ReadFull(r, chunk)
+		last := errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF)
+		if readErr != nil && !last {
+			<-slots
+			fail(fmt.Errorf("failed to read upload content: %w", readErr))
+			break
+		}
+
+		// An empty content still makes a single empty part.
+		if n == 0 && number > 1 {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(number int, chunk []byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			part, err := c.uploadPart(partsCtx, u, uploadID, number, chunk)
+			if err != nil {
+				fail(fmt.Errorf("failed to upload part %d: %w", number, err))
+				return
+			}
+
+			mu.Lock()
+			parts = append(parts, part)
+			mu.Unlock()
+		}(number, chunk[:n])
+
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = fmt.Errorf("chunked upload interrupted: %w", ctx.Err())
+	}
+	if firstErr != nil {
+		if err := u.Abort(context.WithoutCancel(ctx), uploadID); err != nil {
+			return nil, errors.Join(firstErr, fmt.Errorf("failed to abort chunked upload: %w", err))
+		}
+		return nil, firstErr
+	}
+
+	slices.SortFunc(parts, func(a, b UploadedPart) int { return a.Number - b.Number })
+	if err := u.Complete(ctx, uploadID, parts); err != nil {
+		return nil, fmt.Errorf("failed to complete chunked upload: %w", err)
+	}
+
+	return parts, nil
+}
+
+// uploadPart sends a single part of a chunked upload with the retry logic of the client.
+func (c *Client) uploadPart(ctx context.Context, u MultipartUploader, uploadID string, number int, chunk []byte) (UploadedPart, error) {
+	req, err := u.PartRequest(ctx, uploadID, number, chunk)
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	resp, err := c.DoRequest(req, WithRequestContext(ctx))
+	if err != nil {
+		return UploadedPart{}, err
+	}
+	defer resp.Body.Close()
+
+	id, err := u.PartID(number, resp)
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	return UploadedPart{Number: number, ID: id, Size: int64(len(chunk))}, nil
+}
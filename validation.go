@@ -0,0 +1,36 @@
+package retryablehttp
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// WithResponseValidator validates the responses accepted by the policy, e.g. to catch 200 responses
+// carrying an error envelope. fn receives the response along with its body, read into memory; the body
+// remains readable by the caller. An error from fn rejects the response, which is retried like a
+// rejection of the policy, unless the error is wrapped with backoffpolicy.Permanent to fail the request
+// right away.
+func WithResponseValidator(fn func(resp *http.Response, body []byte) error) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("nil response validator")
+		}
+		c.responseValidator = fn
+
+		return nil
+	}
+}
+
+// validateResponse reads the body of resp into memory and runs the response validator on it.
+func (c *Client) validateResponse(resp *http.Response) error {
+	body, err := bufferResponseBody(resp)
+	if err != nil {
+		return err
+	}
+
+	if err := c.responseValidator(resp, body); err != nil {
+		return fmt.Errorf("response validation failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package retryablehttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+)
+
+// ErrInvalidSignature reports a response whose signature doesn't match its content.
+var ErrInvalidSignature = errors.New("invalid response signature")
+
+// Verifier verifies the signature of a response accepted by the policy. body is the response body,
+// read into memory.
+type Verifier interface {
+	Verify(resp *http.Response, body []byte) error
+}
+
+// VerifierFunc adapts a function to the Verifier interface.
+type VerifierFunc func(resp *http.Response, body []byte) error
+
+func (f VerifierFunc) Verify(resp *http.Response, body []byte) error {
+	return f(resp, body)
+}
+
+// WithResponseVerifier verifies the signature of every response accepted by the policy with verifier.
+// A response failing verification fails the request right away, without retrying, since a forged or
+// misconfigured signature doesn't get any better; the body remains readable by the caller otherwise.
+func WithResponseVerifier(verifier Verifier) ClientOption {
+	return func(c *Client) error {
+		if verifier == nil {
+			return fmt.Errorf("nil response verifier")
+		}
+		c.verifier = verifier
+
+		return nil
+	}
+}
+
+// verifyResponse reads the body of resp into memory and verifies it.
+func (c *Client) verifyResponse(resp *http.Response) error {
+	body, err := bufferResponseBody(resp)
+	if err != nil {
+		return err
+	}
+
+	if err := c.verifier.Verify(resp, body); err != nil {
+		return backoffpolicy.Permanent(fmt.Errorf("response verification failed: %w", err))
+	}
+
+	return nil
+}
+
+// HMACVerifier verifies webhook-style response signatures, the counterpart of HMACSigner with
+// HMACCanonicalBody: Header holds the hex-encoded HMAC-SHA256 of the body, preceded by Prefix
+// (e.g. "sha256="), and covering "<timestamp>." first when TimestampHeader is set.
+type HMACVerifier struct {
+	Key             []byte
+	Header          string
+	Prefix          string
+	TimestampHeader string
+}
+
+func (v *HMACVerifier) Verify(resp *http.Response, body []byte) error {
+	if len(v.Key) == 0 {
+		return fmt.Errorf("empty hmac key")
+	}
+	if v.Header == "" {
+		return fmt.Errorf("empty hmac signature header")
+	}
+
+	value, ok := strings.CutPrefix(resp.Header.Get(v.Header), v.Prefix)
+	if !ok || value == "" {
+		return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, v.Header)
+	}
+	signature, err := hex.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("%w: malformed %s header", ErrInvalidSignature, v.Header)
+	}
+
+	message := body
+	if v.TimestampHeader != "" {
+		timestamp := resp.Header.Get(v.TimestampHeader)
+		if timestamp == "" {
+			return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, v.TimestampHeader)
+		}
+		message = append([]byte(timestamp+"."), body...)
+	}
+
+	if !hmac.Equal(signature, hmacSHA256(v.Key, message)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// defaultJWSHeader is the header holding the detached JWS when JWSVerifier.Header is empty.
+const defaultJWSHeader = "X-JWS-Signature"
+
+// JWSVerifier verifies responses signed with a detached JWS (RFC 7515 appendix F), given in compact
+// form with an empty payload ("<header>..<signature>") in Header, "X-JWS-Signature" by default. The
+// body is the payload, base64url-encoded unless the JWS header sets "b64" to false (RFC 7797).
+// Key selects the algorithms accepted: a []byte secret for HS256, HS384 and HS512, an *rsa.PublicKey
+// for RS256, RS384, RS512, PS256, PS384 and PS512, or an *ecdsa.PublicKey for ES256, ES384 and ES512.
+type JWSVerifier struct {
+	Header string
+	Key    any
+}
+
+// jwsHeader holds the JWS header parameters JWSVerifier understands.
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	B64  *bool    `json:"b64"`
+	Crit []string `json:"crit"`
+}
+
+func (v *JWSVerifier) Verify(resp *http.Response, body []byte) error {
+	name := v.Header
+	if name == "" {
+		name = defaultJWSHeader
+	}
+
+	protected, signature, ok := strings.Cut(resp.Header.Get(name), "..")
+	if !ok || protected == "" || signature == "" {
+		return fmt.Errorf("%w: missing or malformed %s header", ErrInvalidSignature, name)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return fmt.Errorf("%w: malformed jws header", ErrInvalidSignature)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return fmt.Errorf("%w: malformed jws header", ErrInvalidSignature)
+	}
+	for _, param := range header.Crit {
+		if param != "b64" {
+			return fmt.Errorf("%w: unsupported critical jws header parameter '%s'", ErrInvalidSignature, param)
+		}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed jws signature", ErrInvalidSignature)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	if header.B64 != nil && !*header.B64 {
+		payload = string(body)
+	}
+
+	return v.verify(header.Alg, []byte(protected+"."+payload), sig)
+}
+
+// verify checks sig over the signing input with the algorithm alg, which must match the key type.
+func (v *JWSVerifier) verify(alg string, input, sig []byte) error {
+	var (
+		newHash func() hash.Hash
+		digest  crypto.Hash
+	)
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		newHash, digest = sha256.New, crypto.SHA256
+	case "384":
+		newHash, digest = sha512.New384, crypto.SHA384
+	case "512":
+		newHash, digest = sha512.New, crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported jws algorithm '%s'", ErrInvalidSignature, alg)
+	}
+	h := newHash()
+	h.Write(input)
+	sum := h.Sum(nil)
+
+	valid := false
+	switch key := v.Key.(type) {
+	case []byte:
+		if !strings.HasPrefix(alg, "HS") {
+			break
+		}
+		mac := hmac.New(newHash, key)
+		mac.Write(input)
+		valid = hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(alg, "RS"):
+			valid = rsa.VerifyPKCS1v15(key, digest, sum, sig) == nil
+		case strings.HasPrefix(alg, "PS"):
+			valid = rsa.VerifyPSS(key, digest, sum, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			break
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		valid = ecdsa.Verify(key, sum, r, s)
+	default:
+		return fmt.Errorf("unsupported jws key type %T", v.Key)
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: jws signature doesn't match with algorithm '%s'", ErrInvalidSignature, alg)
+	}
+
+	return nil
+}
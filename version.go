@@ -0,0 +1,32 @@
+package retryablehttp
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+const modulePath = "github.com/condrove10/retryablehttp"
+
+// defaultUserAgent identifies traffic sent by this package, e.g. "retryablehttp/v1.2.0 Go/1.23.4".
+var defaultUserAgent = "retryablehttp/" + moduleVersion() + " Go/" + strings.TrimPrefix(runtime.Version(), "go")
+
+// moduleVersion returns the version of this module recorded in the build info, or "devel" if unknown.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath && dep.Version != "" && dep.Version != "(devel)" {
+			return dep.Version
+		}
+	}
+
+	return "devel"
+}
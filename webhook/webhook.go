@@ -0,0 +1,387 @@
+// Package webhook delivers outbound webhooks reliably on top of retryablehttp: deliveries are queued,
+// signed, retried over hours along a backoff curve, and reported to a handler once they fail for good.
+package webhook // import "github.com/condrove10/retryablehttp/webhook"
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/condrove10/retryablehttp"
+	"github.com/condrove10/retryablehttp/backoffpolicy"
+	"github.com/condrove10/retryablehttp/internal/outcome"
+)
+
+const (
+	defaultMaxAttempts = 12
+	defaultWorkers     = 4
+)
+
+// defaultSchedule waits a minute before the first redelivery and doubles the wait every time, up to
+// backoffpolicy.MaxDelay, so that 12 attempts span about 8 hours.
+var defaultSchedule backoffpolicy.Schedule = backoffpolicy.Curve{Initial: time.Minute, Multiplier: 2}
+
+// Status is the state of a delivery.
+type Status string
+
+const (
+	// StatusPending deliveries wait for their next attempt.
+	StatusPending Status = "pending"
+	// StatusDelivering deliveries have an attempt in progress.
+	StatusDelivering Status = "delivering"
+	// StatusDelivered deliveries were accepted by the receiver.
+	StatusDelivered Status = "delivered"
+	// StatusFailed deliveries were given up on.
+	StatusFailed Status = "failed"
+)
+
+// Delivery is a webhook to send.
+type Delivery struct {
+	// ID identifies the delivery, and is sent in the webhook-id header. A random ID is assigned when empty.
+	ID      string
+	URL     string
+	Payload []byte
+	// Header holds additional headers; the Content-Type defaults to application/json.
+	Header http.Header
+}
+
+// DeliveryStatus describes the progress of a delivery.
+type DeliveryStatus struct {
+	ID     string
+	Status Status
+	// Attempts is the number of delivery attempts made, each possibly retried by the client.
+	Attempts uint32
+	// LastError is the error of the last failed attempt.
+	LastError error
+	// NextAttempt is when the next attempt is scheduled, for pending deliveries.
+	NextAttempt time.Time
+	// DeliveredAt is when the receiver accepted the delivery.
+	DeliveredAt time.Time
+}
+
+// Config configures a Dispatcher. Zero-valued fields select the defaults.
+type Config struct {
+	// Client sends the deliveries; its own retries absorb short failures within an attempt. Deliveries
+	// whose retries the client aborts, e.g. for a status configured with WithNoRetryStatusCodes, and
+	// those whose request can't be built or the client rejects as misconfigured fail right away instead
+	// of being rescheduled.
+	Client *retryablehttp.Client
+	// Secret signs deliveries following the Standard Webhooks scheme: the webhook-signature header
+	// holds "v1,<base64 HMAC-SHA256 of id.timestamp.payload>". Deliveries aren't signed without it.
+	Secret []byte
+	// Schedule gives the delays between attempts, either a backoffpolicy.Curve or backoffpolicy.Intervals.
+	// It defaults to 1 minute, doubling every attempt.
+	Schedule backoffpolicy.Schedule
+	// MaxAttempts is the number of attempts before a delivery fails. It defaults to one attempt per
+	// interval plus the first one for backoffpolicy.Intervals, and to 12 otherwise.
+	MaxAttempts uint32
+	// Workers is the number of deliveries sent concurrently. It defaults to 4.
+	Workers int
+	// OnFailure is called when a delivery is given up on.
+	OnFailure func(d Delivery, status DeliveryStatus)
+	// OnStatusChange is called synchronously whenever a delivery is queued, rescheduled, delivered or
+	// given up on, so its state can be persisted and restored with Restore after a restart.
+	OnStatusChange func(d Delivery, status DeliveryStatus)
+}
+
+// Dispatcher queues and delivers webhooks. Deliveries and their statuses are kept in memory.
+type Dispatcher struct {
+	cfg Config
+
+	mu         sync.Mutex
+	deliveries map[string]*entry
+	ready      chan string
+	done       chan struct{}
+	closed     bool
+	wg         sync.WaitGroup
+}
+
+// entry tracks a queued delivery.
+type entry struct {
+	delivery Delivery
+	status   DeliveryStatus
+	timer    *time.Timer
+}
+
+// New returns a Dispatcher that starts delivering right away.
+func New(cfg Config) (*Dispatcher, error) {
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+	if cfg.Workers < 0 {
+		return nil, fmt.Errorf("invalid workers value '%d'", cfg.Workers)
+	}
+	if cfg.Schedule == nil {
+		cfg.Schedule = defaultSchedule
+	}
+	if err := cfg.Schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+		if intervals, ok := cfg.Schedule.(backoffpolicy.Intervals); ok {
+			cfg.MaxAttempts = uint32(len(intervals)) + 1
+		}
+	}
+	if cfg.Workers == 0 {
+		cfg.Workers = defaultWorkers
+	}
+
+	d := &Dispatcher{
+		cfg:        cfg,
+		deliveries: map[string]*entry{},
+		ready:      make(chan string),
+		done:       make(chan struct{}),
+	}
+	for range cfg.Workers {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	return d, nil
+}
+
+// Enqueue queues a delivery for immediate sending and returns its ID.
+func (d *Dispatcher) Enqueue(delivery Delivery) (string, error) {
+	if delivery.URL == "" {
+		return "", fmt.Errorf("empty delivery url")
+	}
+	if delivery.ID == "" {
+		delivery.ID = newID()
+	}
+
+	status := DeliveryStatus{ID: delivery.ID, Status: StatusPending, NextAttempt: time.Now()}
+	if err := d.add(delivery, status); err != nil {
+		return "", err
+	}
+
+	return delivery.ID, nil
+}
+
+// Restore queues a pending delivery saved through Config.OnStatusChange, e.g. after a restart. The
+// delivery keeps its attempt count, and its next attempt is made at status.NextAttempt, or right away
+// if that time has passed.
+func (d *Dispatcher) Restore(delivery Delivery, status DeliveryStatus) error {
+	if delivery.URL == "" {
+		return fmt.Errorf("empty delivery url")
+	}
+	if delivery.ID == "" || status.ID != delivery.ID {
+		return fmt.Errorf("invalid delivery id '%s'", delivery.ID)
+	}
+	if status.Status != StatusPending && status.Status != StatusDelivering {
+		return fmt.Errorf("delivery '%s' isn't pending", delivery.ID)
+	}
+
+	// A delivery saved while delivering was interrupted, and is attempted again.
+	status.Status = StatusPending
+
+	return d.add(delivery, status)
+}
+
+// add tracks a new delivery and schedules its next attempt.
+func (d *Dispatcher) add(delivery Delivery, status DeliveryStatus) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return fmt.Errorf("dispatcher closed")
+	}
+	if _, ok := d.deliveries[delivery.ID]; ok {
+		d.mu.Unlock()
+		return fmt.Errorf("duplicate delivery id '%s'", delivery.ID)
+	}
+
+	e := &entry{delivery: delivery, status: status}
+	d.deliveries[delivery.ID] = e
+	d.schedule(e, max(time.Until(status.NextAttempt), 0))
+	d.mu.Unlock()
+
+	d.statusChanged(delivery, status)
+
+	return nil
+}
+
+// Status returns the status of a delivery.
+func (d *Dispatcher) Status(id string) (DeliveryStatus, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.deliveries[id]
+	if !ok {
+		return DeliveryStatus{}, false
+	}
+
+	return e.status, true
+}
+
+// Forget drops a delivered or failed delivery from memory.
+func (d *Dispatcher) Forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.deliveries[id]; ok && (e.status.Status == StatusDelivered || e.status.Status == StatusFailed) {
+		delete(d.deliveries, id)
+	}
+}
+
+// Close stops the dispatcher: pending deliveries are no longer attempted, and attempts in progress
+// are waited for.
+func (d *Dispatcher) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	for _, e := range d.deliveries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+	close(d.done)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+
+	return nil
+}
+
+// schedule hands e to the workers after wait. d.mu must be held.
+func (d *Dispatcher) schedule(e *entry, wait time.Duration) {
+	id := e.delivery.ID
+	e.timer = time.AfterFunc(wait, func() {
+		select {
+		case d.ready <- id:
+		case <-d.done:
+		}
+	})
+}
+
+// work delivers the deliveries handed over until the dispatcher is closed.
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case id := <-d.ready:
+			d.attempt(id)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once, and reschedules it or gives up on it if that fails.
+func (d *Dispatcher) attempt(id string) {
+	d.mu.Lock()
+	e, ok := d.deliveries[id]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	e.status.Status = StatusDelivering
+	e.status.Attempts++
+	delivery := e.delivery
+	d.mu.Unlock()
+
+	err := d.send(delivery)
+
+	d.mu.Lock()
+	status := d.settle(e, err)
+	d.mu.Unlock()
+
+	d.statusChanged(delivery, status)
+	if status.Status == StatusFailed && d.cfg.OnFailure != nil {
+		go d.cfg.OnFailure(delivery, status)
+	}
+}
+
+// settle records the outcome of an attempt of e, rescheduling it if needed, and returns its new
+// status. d.mu must be held.
+func (d *Dispatcher) settle(e *entry, err error) DeliveryStatus {
+	if err == nil {
+		e.status.Status, e.status.LastError, e.status.NextAttempt, e.status.DeliveredAt = StatusDelivered, nil, time.Time{}, time.Now()
+		return e.status
+	}
+	e.status.LastError = err
+
+	// Pending deliveries are left as they are once the dispatcher is closed.
+	if d.closed {
+		e.status.Status = StatusPending
+		return e.status
+	}
+
+	// Requests that can't be built, or that the client aborted on, wouldn't fare any better later.
+	if outcome.Final(err) || e.status.Attempts >= d.cfg.MaxAttempts {
+		e.status.Status, e.status.NextAttempt = StatusFailed, time.Time{}
+		return e.status
+	}
+
+	wait := d.cfg.Schedule.Delay(e.status.Attempts)
+	e.status.Status, e.status.NextAttempt = StatusPending, time.Now().Add(wait)
+	d.schedule(e, wait)
+
+	return e.status
+}
+
+// statusChanged reports the new status of a delivery to Config.OnStatusChange.
+func (d *Dispatcher) statusChanged(delivery Delivery, status DeliveryStatus) {
+	if d.cfg.OnStatusChange != nil {
+		d.cfg.OnStatusChange(delivery, status)
+	}
+}
+
+// send makes a single delivery attempt through the client.
+func (d *Dispatcher) send(delivery Delivery) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return backoffpolicy.Permanent(fmt.Errorf("failed to create delivery request: %w", err))
+	}
+	for k, v := range delivery.Header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("webhook-id", delivery.ID)
+	req.Header.Set("webhook-timestamp", timestamp)
+	if len(d.cfg.Secret) > 0 {
+		req.Header.Set("webhook-signature", "v1,"+Sign(d.cfg.Secret, delivery.ID, timestamp, delivery.Payload))
+	}
+
+	resp, err := d.cfg.Client.DoRequest(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Sign returns the base64 HMAC-SHA256 signature of a delivery, as sent in the webhook-signature header
+// after the "v1," prefix, so receivers can verify deliveries.
+func Sign(secret []byte, id, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(payload)
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newID returns a random delivery ID.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	return "msg_" + hex.EncodeToString(b[:])
+}